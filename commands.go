@@ -0,0 +1,543 @@
+package main
+
+// The bpdaily subcommands and the parsing of their command line arguments.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/mikebway/bpdaily/dlycsv"
+)
+
+// The general usage text, displayed for --help and appended to usage errors.
+const usageText = `
+Usage:
+
+  bpdaily input-file-path.csv output-file-path
+  bpdaily [command] [options] arguments...
+
+A file path of "-", or an omitted file path, selects standard input or output.
+
+Commands:
+
+  daily      Collate readings into one line per day (the default command)
+  chart      Render readings as an SVG chart
+  update     Merge new readings into an existing daily file
+  stats      Display summary statistics for an input file
+  protocol   Report on a 7-day home monitoring protocol, such as that of the ESH
+  validate   Check that an input file can be converted
+
+Options:
+
+  --help     Display usage information, for bpdaily or a single command
+  --version  Display the bpdaily version
+
+`
+
+// command describes one of the bpdaily subcommands.
+type command struct {
+	name     string                                      // The name used to invoke the command
+	synopsis string                                      // The arguments that the command expects
+	nargs    int                                         // The number of file path arguments that the command accepts
+	define   func(fs *flag.FlagSet) func([]string) error // Defines the command flags, returning the function that runs the command
+}
+
+// The commands that bpdaily supports; the first is the default.
+var commands = []*command{
+	{name: "daily", synopsis: "[options] [input-file-path.csv] [output-file-path]", nargs: 2, define: dailyCommand},
+	{name: "chart", synopsis: "[options] [input-file-path.csv] [output-file-path.svg]", nargs: 2, define: chartCommand},
+	{name: "update", synopsis: "[options] daily-file-path.csv [input-file-path.csv]", nargs: 2, define: updateCommand},
+	{name: "stats", synopsis: "[options] [input-file-path.csv]", nargs: 1, define: statsCommand},
+	{name: "protocol", synopsis: "[options] [input-file-path.csv]", nargs: 1, define: protocolCommand},
+	{name: "validate", synopsis: "[options] [input-file-path.csv]", nargs: 1, define: validateCommand},
+}
+
+// usageError reports that the command line arguments were not valid.
+type usageError struct {
+	message string // What was wrong with the arguments
+	usage   string // The usage text that explains what should have been given
+}
+
+// Error returns the reason for the failure followed by the usage text.
+func (e *usageError) Error() string {
+	return e.message + "\n" + e.usage
+}
+
+// execute runs the command selected by the given arguments, returning any error that occurs.
+func execute(args []string) error {
+
+	// Handle the options that are not specific to a single command
+	if len(args) > 0 {
+		switch args[0] {
+		case "-h", "-help", "--help", "help":
+			fmt.Fprint(stdout, usageText)
+			return nil
+		case "-version", "--version", "version":
+			fmt.Fprintf(stdout, "bpdaily version %s\n", version)
+			return nil
+		}
+	}
+
+	// If the first argument names a command, run that command
+	if len(args) > 0 {
+		if cmd := findCommand(args[0]); cmd != nil {
+			return cmd.run(args[1:])
+		}
+	}
+
+	// Otherwise we run the default command with all of the arguments
+	return commands[0].run(args)
+}
+
+// run parses the flags and arguments of the command and, if they are acceptable,
+// executes it.
+func (cmd *command) run(args []string) error {
+
+	// Build the flag set for the command, silencing it since we report errors ourselves
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	action := cmd.define(fs)
+
+	// Parse the arguments, allowing flags to be mixed in with the positional arguments
+	positional, err := parseInterspersed(fs, args)
+	if err == flag.ErrHelp {
+		fmt.Fprint(stdout, cmd.usage(fs))
+		return nil
+	} else if err != nil {
+		return &usageError{message: err.Error(), usage: cmd.usage(fs)}
+	} else if err = checkExclusiveFlags(fs); err != nil {
+		return &usageError{message: err.Error(), usage: cmd.usage(fs)}
+	}
+
+	// Make sure we have not been given too many file paths, standing in
+	// standard input or output for any that have been omitted
+	if len(positional) > cmd.nargs {
+		return &usageError{
+			message: fmt.Sprintf("expected at most %d file path arguments but found %d", cmd.nargs, len(positional)),
+			usage:   cmd.usage(fs),
+		}
+	}
+	for len(positional) < cmd.nargs {
+		positional = append(positional, dlycsv.StdioPath)
+	}
+
+	// Let the command do its thing
+	return action(positional)
+}
+
+// The pairs of flags that set the same option, only one of which may be given.
+var exclusiveFlags = [][2]string{
+	{"from", "last"},
+	{"format", "columns"},
+}
+
+// checkExclusiveFlags returns an error if both flags of an exclusive pair were given,
+// rather than letting whichever came last quietly win.
+func checkExclusiveFlags(fs *flag.FlagSet) error {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	for _, pair := range exclusiveFlags {
+		if given[pair[0]] && given[pair[1]] {
+			return fmt.Errorf("--%s and --%s cannot be given together", pair[0], pair[1])
+		}
+	}
+	return nil
+}
+
+// usage returns the usage text for the command, including a description of its flags.
+func (cmd *command) usage(fs *flag.FlagSet) string {
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s\n  bpdaily %s %s\n", usageText, cmd.name, cmd.synopsis)
+
+	// Describe the flags, if there are any
+	fs.VisitAll(func(f *flag.Flag) {
+		fmt.Fprintf(&sb, "\n  --%s\n    \t%s", f.Name, f.Usage)
+		if f.DefValue != "" && f.DefValue != "false" {
+			fmt.Fprintf(&sb, " (default %q)", f.DefValue)
+		}
+	})
+	sb.WriteString("\n\n")
+	return sb.String()
+}
+
+// parseInterspersed parses the given arguments with the flag set, allowing flags to
+// appear after positional arguments. Arguments that follow a "--" terminator are always
+// treated as positional. Returns the positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+
+	// Split off anything following a terminator
+	var trailing []string
+	for i, arg := range args {
+		if arg == "--" {
+			trailing = args[i+1:]
+			args = args[:i]
+			break
+		}
+	}
+
+	// Keep parsing until we run out of arguments, collecting positional
+	// arguments each time the flag set stops at one
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	return append(positional, trailing...), nil
+}
+
+// findCommand returns the command with the given name, or nil if there is no such command.
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// dailyCommand defines the flags of the daily command and returns the function that
+// translates the input CSV file into the daily output CSV file.
+func dailyCommand(fs *flag.FlagSet) func([]string) error {
+
+	var opts dlycsv.Options
+	overwrite, inputs := defineConversionFlags(fs, &opts)
+	fs.BoolVar(&opts.Stats, "stats", false, "Append per-day summary statistics columns")
+	fs.Var(&rollingValue{&opts.Rolling}, "rolling", "Append rolling average and trend columns for windows of this many days, e.g. 7,30")
+	fs.Var(&periodValue{&opts.GroupBy}, "group-by", "Write one line of statistics per period rather than the readings of each day: day, week, iso-week, month")
+	fs.Var(&outputFormatValue{&opts.Output}, "output-format", "The output file format: auto (from the output file extension), csv, xlsx, svg, html")
+
+	return func(args []string) error {
+		return inputs.writeRejects(dlycsv.ConvertFiles(inputs.paths(args[0]), args[1], *overwrite, opts))
+	}
+}
+
+// chartCommand defines the flags of the chart command and returns the function that
+// renders the input CSV file as an SVG chart.
+func chartCommand(fs *flag.FlagSet) func([]string) error {
+
+	var opts dlycsv.Options
+	overwrite, inputs := defineConversionFlags(fs, &opts)
+
+	return func(args []string) error {
+		opts.Output = dlycsv.SVGOutput
+		return inputs.writeRejects(dlycsv.ConvertFiles(inputs.paths(args[0]), args[1], *overwrite, opts))
+	}
+}
+
+// defineConversionFlags defines the flags shared by the commands that convert the input
+// file into an output file, returning the value of the overwrite flag and the input flags.
+func defineConversionFlags(fs *flag.FlagSet, opts *dlycsv.Options) (*bool, *inputFlags) {
+	overwrite := fs.Bool("overwrite", false, "Replace the output file if it already exists")
+	inputs := defineInputFlags(fs, opts)
+	defineLayoutFlags(fs, opts)
+	fs.Var(&policyValue{&opts.Policy}, "policy", "How to resolve several readings in a slot or day: all, first, last, highest, lowest, average, median")
+	return overwrite, inputs
+}
+
+// defineLayoutFlags defines the flags, shared by the commands that write daily files,
+// that decide which columns the daily file has.
+func defineLayoutFlags(fs *flag.FlagSet, opts *dlycsv.Options) {
+	fs.Var(&slotsValue{&opts.Slots}, "slots", "Named time slots for readings, e.g. Morning=04:00-12:00,Evening=18:00-04:00")
+	fs.BoolVar(&opts.Classify, "classify", false, "Add the blood pressure category of each reading and each day")
+	fs.Var(&guidelineValue{&opts.Guideline}, "guideline", "The guideline for categories and chart thresholds: aha2017, esh2023")
+	fs.BoolVar(&opts.FillGaps, "fill-gaps", false, "Write an empty day for each day without readings, with a leading date column")
+	fs.Var(&dateValue{&opts.FillFrom}, "fill-from", "The first day of the calendar filled by --fill-gaps, e.g. 2020-04-01")
+	fs.Var(&dateValue{&opts.FillTo}, "fill-to", "The last day of the calendar filled by --fill-gaps, e.g. 2020-05-31")
+}
+
+// updateCommand defines the flags of the update command and returns the function that
+// merges the readings of the input file into an existing daily CSV file.
+func updateCommand(fs *flag.FlagSet) func([]string) error {
+
+	var opts dlycsv.Options
+	inputs := defineInputFlags(fs, &opts)
+	defineLayoutFlags(fs, &opts)
+	fs.BoolVar(&opts.Stats, "stats", false, "Append per-day summary statistics columns, if the daily file does not have them already")
+	fs.Var(&rollingValue{&opts.Rolling}, "rolling", "Rolling average and trend windows, in days, e.g. 7,30, in place of those the daily file has")
+
+	return func(args []string) error {
+		return inputs.writeRejects(dlycsv.UpdateDailyFile(args[0], inputs.paths(args[1]), opts))
+	}
+}
+
+// statsCommand defines the flags of the stats command and returns the function that
+// displays summary statistics for the input CSV file.
+func statsCommand(fs *flag.FlagSet) func([]string) error {
+
+	var opts dlycsv.Options
+	inputs := defineInputFlags(fs, &opts)
+	fs.Var(&guidelineValue{&opts.Guideline}, "guideline", "The guideline for the category of the mean pressures: aha2017, esh2023")
+	fs.Var(&rollingValue{&opts.Rolling}, "rolling", "Display rolling averages for windows of this many days up to the last day, e.g. 7,30")
+
+	return func(args []string) error {
+		summary, err := dlycsv.SummarizeFiles(inputs.paths(args[0]), opts)
+		if err = inputs.writeRejects(err); err != nil {
+			return err
+		}
+
+		// Display what we found
+		fmt.Fprintf(stdout, "Readings:  %d over %d days\n", summary.Readings, summary.Days)
+		if summary.Duplicates > 0 {
+			fmt.Fprintf(stdout, "Repeats:   %d duplicate readings left out\n", summary.Duplicates)
+		}
+		if summary.Readings > 0 {
+			fmt.Fprintf(stdout, "First:     %s\n", summary.First.Format("2006-01-02 15:04:05"))
+			fmt.Fprintf(stdout, "Last:      %s\n", summary.Last.Format("2006-01-02 15:04:05"))
+			printRange("Systolic", summary.Systolic)
+			printRange("Diastolic", summary.Diastolic)
+			printRange("Pulse", summary.Pulse)
+			guideline := opts.Guideline
+			if guideline == nil {
+				guideline = dlycsv.AHA2017
+			}
+			category := guideline.ClassifyMeans(summary.Systolic.Mean, summary.Diastolic.Mean)
+			fmt.Fprintf(stdout, "Category:  %s (%s)\n", guideline.Label(category), guideline)
+			printTrend(summary.Trend)
+			printRolling(summary.Rolling)
+			printGaps(summary.Gaps)
+		}
+		return nil
+	}
+}
+
+// protocolCommand defines the flags of the protocol command and returns the function that
+// displays how well the readings of the input CSV file keep to a home monitoring protocol.
+func protocolCommand(fs *flag.FlagSet) func([]string) error {
+
+	var opts dlycsv.Options
+	protocol := dlycsv.ESHHomeProtocol
+	inputs := defineInputFlags(fs, &opts)
+	fs.Var(&slotsValue{&opts.Slots}, "slots", "The measurement sessions of each day (default Morning=04:00-12:00,Evening=16:00-04:00)")
+	fs.Var(&daysValue{&protocol.Days}, "days", "The number of days that the protocol lasts, ending on the last day with readings unless --from or --to is given")
+
+	return func(args []string) error {
+		report, err := dlycsv.EvaluateProtocolFiles(inputs.paths(args[0]), protocol, opts)
+		if err = inputs.writeRejects(err); err != nil {
+			return err
+		}
+
+		// Describe the protocol and how each of its days went
+		followed := report.Protocol
+		fmt.Fprintf(stdout, "Protocol:  %s, %d days from %s to %s\n", followed.Name, followed.Days,
+			report.From.Format("2006-01-02"), report.To.Format("2006-01-02"))
+		var sessions []string
+		for _, session := range followed.Sessions {
+			sessions = append(sessions, session.String())
+		}
+		fmt.Fprintf(stdout, "Sessions:  %s, %d readings each\n", strings.Join(sessions, ", "), followed.SessionReadings)
+		for _, day := range report.Days {
+			line := day.Date.Format("2006-01-02")
+			for _, session := range day.Sessions {
+				line += fmt.Sprintf("  %s %d", session.Name, len(session.Readings))
+			}
+			if day.Discarded {
+				line += "  (first day, not counted)"
+			}
+			fmt.Fprintf(stdout, "           %s\n", line)
+		}
+
+		// Then the mean of the readings that count, and what it means
+		fmt.Fprintf(stdout, "Counted:   %d of %d readings\n", report.Stats.Count, report.Expected)
+		if report.Stats.Count > 0 {
+			pulse := ""
+			if report.Stats.Pulse.Max > 0 {
+				pulse = fmt.Sprintf(", pulse %.1f", report.Stats.Pulse.Mean)
+			}
+			fmt.Fprintf(stdout, "Mean:      %.1f/%.1f mmHg%s\n", report.Stats.Systolic.Mean, report.Stats.Diastolic.Mean, pulse)
+		}
+		switch {
+		case !report.Valid():
+			fmt.Fprintf(stdout, "Result:    not valid, at least %d readings must be counted\n", followed.MinimumReadings)
+		case report.Raised():
+			fmt.Fprintf(stdout, "Result:    at or above the home threshold of %d/%d mmHg\n", followed.Systolic, followed.Diastolic)
+		default:
+			fmt.Fprintf(stdout, "Result:    below the home threshold of %d/%d mmHg\n", followed.Systolic, followed.Diastolic)
+		}
+		printProtocolShortfalls(report)
+		return nil
+	}
+}
+
+// printProtocolShortfalls displays the sessions that were missed, and the readings that
+// were left out, for the protocol command.
+func printProtocolShortfalls(report *dlycsv.ProtocolReport) {
+	switch len(report.Missed) {
+	case 0:
+		fmt.Fprintln(stdout, "Missed:    none")
+	case 1:
+		fmt.Fprintln(stdout, "Missed:    1 session short of readings")
+	default:
+		fmt.Fprintf(stdout, "Missed:    %d sessions short of readings\n", len(report.Missed))
+	}
+	for _, session := range report.Missed {
+		fmt.Fprintf(stdout, "           %s %s, %d of %d readings\n", session.Date.Format("2006-01-02"),
+			session.Name, len(session.Readings), report.Protocol.SessionReadings)
+	}
+	var omitted []string
+	if report.FirstDayReadings > 0 {
+		omitted = append(omitted, fmt.Sprintf("%d from the first day", report.FirstDayReadings))
+	}
+	if report.ExtraReadings > 0 {
+		omitted = append(omitted, fmt.Sprintf("%d extra in a session", report.ExtraReadings))
+	}
+	if report.OutsideReadings > 0 {
+		omitted = append(omitted, fmt.Sprintf("%d outside the sessions", report.OutsideReadings))
+	}
+	if len(omitted) > 0 {
+		fmt.Fprintf(stdout, "Left out:  %s\n", strings.Join(omitted, ", "))
+	}
+}
+
+// defineInputFlags defines the flags, shared by every command, that control which input
+// files are read and how they are parsed, returning the values of those flags that are not
+// conversion options.
+func defineInputFlags(fs *flag.FlagSet, opts *dlycsv.Options) *inputFlags {
+	inputs := &inputFlags{}
+	fs.Var(&pathsValue{&inputs.extra}, "input", "Another input file, wildcard pattern or directory whose readings are merged in; may be repeated")
+	fs.Var(&formatValue{&opts.Format}, "format", "The input file format: auto, "+formatNames())
+	fs.Var(&columnsValue{&opts.Format}, "columns", "Describe the columns of a generic input file, e.g. datetime=Timestamp,systolic=SYS,diastolic=DIA,pulse=HR")
+	fs.Var(&zoneValue{&opts.InputZone}, "input-zone", "The time zone of input times that have no offset, e.g. Europe/London or -05:00 (default UTC)")
+	fs.Var(&zoneValue{&opts.DisplayZone}, "display-zone", "The time zone in which readings are gathered into days and written, e.g. America/New_York or Local")
+	fs.Var(&dayStartValue{&opts.DayStart}, "day-start", "The time of day at which each day starts, before 12:00; earlier readings join the day before")
+	fs.Var(&dateValue{&opts.From}, "from", "Leave out readings taken before this day, e.g. 2020-04-01")
+	fs.Var(&dateValue{&opts.To}, "to", "Leave out readings taken after this day, e.g. 2020-05-31")
+	fs.Var(&lastValue{&opts.From}, "last", "Keep only the readings of the last period up to today, e.g. 30d, 2w, 6m, 1y")
+	fs.BoolVar(&opts.Lenient, "lenient", false, "Skip records that cannot be read, such as those with too many fields, rather than fail")
+	fs.Var(&plausibilityValue{&opts.Implausible}, "implausible", "What to do with implausible readings: flag, reject, fix")
+	fs.Var(&limitsValue{&opts.Limits}, "limits", "Plausible reading ranges, e.g. systolic=60-260,diastolic=30-160,pulse=25-220")
+	fs.StringVar(&inputs.rejectsPath, "rejects", "", "Write a CSV report of the rejected input records to this file path, or - for standard error")
+	opts.Rejected = inputs.addReject
+	return inputs
+}
+
+// inputFlags holds the values of the input flags that are not conversion options, and
+// collects the input records rejected by a command for the --rejects flag.
+type inputFlags struct {
+	extra       []string        // The input paths given with --input, after the positional input path
+	rejectsPath string          // The path of the rejects report file, empty if no report is wanted
+	rejects     []dlycsv.Reject // The records rejected so far
+}
+
+// paths returns the positional input path followed by those given with --input.
+func (f *inputFlags) paths(inputPath string) []string {
+	return append([]string{inputPath}, f.extra...)
+}
+
+// addReject collects another rejected record.
+func (f *inputFlags) addReject(reject dlycsv.Reject) {
+	f.rejects = append(f.rejects, reject)
+}
+
+// writeRejects writes the rejects report, if one is wanted and the command that collected
+// it succeeded, returning the error of the command or else any error writing the report.
+// A report path of "-" selects standard error, as it does for WriteRejectsFile, written
+// here so that it can be captured when unit testing.
+func (f *inputFlags) writeRejects(err error) error {
+	if err != nil || f.rejectsPath == "" {
+		return err
+	} else if f.rejectsPath == dlycsv.StdioPath {
+		return dlycsv.WriteRejects(stderr, f.rejects)
+	}
+	return dlycsv.WriteRejectsFile(f.rejectsPath, f.rejects)
+}
+
+// printGaps displays the streaks of days without readings for the stats command.
+func printGaps(gaps []dlycsv.Gap) {
+	missed := 0
+	for _, gap := range gaps {
+		missed += gap.Days
+	}
+	switch len(gaps) {
+	case 0:
+		fmt.Fprintln(stdout, "Gaps:      none")
+	case 1:
+		fmt.Fprintf(stdout, "Gaps:      %d days without readings in 1 streak\n", missed)
+	default:
+		fmt.Fprintf(stdout, "Gaps:      %d days without readings in %d streaks\n", missed, len(gaps))
+	}
+	for _, gap := range gaps {
+		if gap.Days == 1 {
+			fmt.Fprintf(stdout, "           %s\n", gap.From.Format("2006-01-02"))
+		} else {
+			fmt.Fprintf(stdout, "           %s to %s, %d days\n", gap.From.Format("2006-01-02"), gap.To.Format("2006-01-02"), gap.Days)
+		}
+	}
+}
+
+// printTrend displays the slopes of the trend of every reading, if there is one.
+func printTrend(trend *dlycsv.Trend) {
+	if trend == nil {
+		return
+	}
+	pulse := ""
+	if trend.HasPulse {
+		pulse = fmt.Sprintf(", pulse %+.2f", trend.Pulse)
+	}
+	fmt.Fprintf(stdout, "Trend:     systolic %+.2f, diastolic %+.2f%s per day\n", trend.Systolic, trend.Diastolic, pulse)
+}
+
+// printRolling displays the rolling averages that end on the last day with readings.
+func printRolling(averages []dlycsv.RollingAverage) {
+	for index, average := range averages {
+		label := ""
+		if index == 0 {
+			label = "Rolling:"
+		}
+		pulse := ""
+		if average.Stats.Pulse.Max > 0 {
+			pulse = fmt.Sprintf(", pulse %.1f", average.Stats.Pulse.Mean)
+		}
+		fmt.Fprintf(stdout, "%-10s %d days to %s, mean %.1f/%.1f%s from %d readings\n", label, average.Days,
+			average.End.Format("2006-01-02"), average.Stats.Systolic.Mean, average.Stats.Diastolic.Mean, pulse, average.Stats.Count)
+	}
+}
+
+// printRange displays the spread of one set of values for the stats command.
+func printRange(name string, r dlycsv.Range) {
+	fmt.Fprintf(stdout, "%-10s mean %.1f, min %d, max %d\n", name+":", r.Mean, r.Min, r.Max)
+}
+
+// validateCommand defines the flags of the validate command and returns the function that
+// checks whether the input CSV file can be converted.
+func validateCommand(fs *flag.FlagSet) func([]string) error {
+
+	var opts dlycsv.Options
+	inputs := defineInputFlags(fs, &opts)
+
+	return func(args []string) error {
+		summary, err := dlycsv.SummarizeFiles(inputs.paths(args[0]), opts)
+		if err = inputs.writeRejects(err); err != nil {
+			return err
+		}
+
+		// Report how much of the file is usable
+		name := args[0]
+		if name == dlycsv.StdioPath {
+			name = "standard input"
+		}
+		if len(inputs.extra) > 0 {
+			name += fmt.Sprintf(" plus %d more", len(inputs.extra))
+		}
+		fmt.Fprintf(stdout, "%s is valid: %d readings over %d days, %d records ignored",
+			name, summary.Readings, summary.Days, summary.Discarded)
+		if summary.Duplicates > 0 {
+			fmt.Fprintf(stdout, ", %d duplicates left out", summary.Duplicates)
+		}
+		fmt.Fprintln(stdout)
+		return nil
+	}
+}
@@ -0,0 +1,310 @@
+package dlycsv
+
+// The input format of the export.xml file found in an Apple Health export, read as a
+// stream so that exports of many years of heart rate samples can be handled.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// The HealthKit type identifiers that we are interested in.
+const (
+	hkBloodPressure = "HKCorrelationTypeIdentifierBloodPressure"
+	hkSystolic      = "HKQuantityTypeIdentifierBloodPressureSystolic"
+	hkDiastolic     = "HKQuantityTypeIdentifierBloodPressureDiastolic"
+	hkHeartRate     = "HKQuantityTypeIdentifierHeartRate"
+)
+
+// The layout of the dates in an Apple Health export.
+const appleHealthDateLayout = "2006-01-02 15:04:05 -0700"
+
+// The furthest that a heart rate sample may be from a blood pressure reading for it to
+// be taken as the pulse of the reading.
+const heartRateTolerance = 2 * time.Minute
+
+// AppleHealthFormat is the InputFormat of the export.xml file of an Apple Health export.
+// Blood pressure correlations provide the systolic and diastolic pressures of each reading,
+// and the heart rate sample nearest to the time of each reading provides its pulse.
+// Systolic and diastolic samples that are not part of a correlation are paired by their
+// start date and source.
+var AppleHealthFormat InputFormat = appleHealthFormat{}
+
+// appleHealthFormat is the DocumentFormat implementation of AppleHealthFormat.
+type appleHealthFormat struct{}
+
+// Name returns the name of the format.
+func (appleHealthFormat) Name() string {
+	return "applehealth"
+}
+
+// Detect never recognizes a CSV header record; Apple Health exports are XML documents.
+func (appleHealthFormat) Detect(header []string) (RecordParser, bool) {
+	return nil, false
+}
+
+// Sniff returns true if the input starts like an XML document.
+func (appleHealthFormat) Sniff(prefix []byte) bool {
+	prefix = bytes.TrimLeft(bytes.TrimPrefix(prefix, []byte("\ufeff")), " \t\r\n")
+	return bytes.HasPrefix(prefix, []byte("<"))
+}
+
+// ParseDocument streams the export, returning the blood pressure readings that it
+// contains and the blood pressure samples that could not be made into readings.
+func (appleHealthFormat) ParseDocument(ctx context.Context, r io.Reader) ([]Reading, []Reject, error) {
+	parser := &appleHealthParser{
+		lines:   &lineTracker{r: r},
+		covered: make(map[string]bool),
+		loose:   make(map[string]*bloodPressureSample),
+	}
+	return parser.parse(ctx)
+}
+
+// bloodPressureSample gathers the systolic and diastolic values of one reading as they
+// are found in the export.
+type bloodPressureSample struct {
+	time      time.Time // The start date of the sample
+	key       string    // The start date and source of the sample, identifying it
+	systolic  string    // The systolic value, empty until found
+	diastolic string    // The diastolic value, empty until found
+	line      int       // The line of the export on which the sample started
+}
+
+// heartRateSample is a single heart rate sample, kept small since there may be millions.
+type heartRateSample struct {
+	time  int64 // The start date of the sample, in Unix seconds
+	value int32 // The heart rate in beats per minute
+}
+
+// appleHealthParser holds the state of the parsing of one Apple Health export.
+type appleHealthParser struct {
+	lines       *lineTracker                    // Tracks the line numbers of the export
+	correlation *bloodPressureSample            // The blood pressure correlation being read, if any
+	samples     []*bloodPressureSample          // The blood pressure samples found, in export order
+	covered     map[string]bool                 // The keys of the samples that came from correlations
+	loose       map[string]*bloodPressureSample // Samples assembled from records outside any correlation
+	heartRates  []heartRateSample               // The heart rate samples found
+	rejects     []Reject                        // The samples that could not be used
+}
+
+// parse reads the whole export and assembles the readings that it contains.
+func (p *appleHealthParser) parse(ctx context.Context) ([]Reading, []Reject, error) {
+
+	// Work through the export a token at a time
+	decoder := xml.NewDecoder(p.lines)
+	decoder.Strict = false
+	rooted := false
+	for {
+		token, err := decoder.Token()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, nil, ctxErr
+		} else if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, classifiedErrorf(FormatError, "failed to read Apple Health export: %w", err)
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			// The document must be an Apple Health export
+			if !rooted {
+				if element.Name.Local != "HealthData" {
+					return nil, nil, classifiedErrorf(FormatError, "input is not an Apple Health export")
+				}
+				rooted = true
+				continue
+			}
+			p.startElement(element, p.lines.lineAt(decoder.InputOffset()))
+
+		case xml.EndElement:
+			if element.Name.Local == "Correlation" && p.correlation != nil {
+				p.samples = append(p.samples, p.correlation)
+				p.covered[p.correlation.key] = true
+				p.correlation = nil
+			}
+		}
+	}
+	if !rooted {
+		return nil, nil, classifiedErrorf(FormatError, "input is not an Apple Health export")
+	}
+
+	// Add the loose samples that were not already supplied by correlations
+	for key, sample := range p.loose {
+		if !p.covered[key] {
+			p.samples = append(p.samples, sample)
+		}
+	}
+	sort.SliceStable(p.samples, func(i, j int) bool { return p.samples[i].line < p.samples[j].line })
+
+	// Turn the samples into readings
+	sort.Slice(p.heartRates, func(i, j int) bool { return p.heartRates[i].time < p.heartRates[j].time })
+	var readings []Reading
+	for _, sample := range p.samples {
+		reading, err := p.reading(sample)
+		if err != nil {
+			p.rejects = append(p.rejects, Reject{Line: sample.line, Reason: err.Error()})
+			continue
+		}
+		readings = append(readings, reading)
+	}
+	return readings, p.rejects, nil
+}
+
+// startElement takes what we need from the start of an element of the export.
+func (p *appleHealthParser) startElement(element xml.StartElement, line int) {
+
+	// Gather the attributes that matter to us
+	var kind, start, source, value string
+	for _, attr := range element.Attr {
+		switch attr.Name.Local {
+		case "type":
+			kind = attr.Value
+		case "startDate":
+			start = attr.Value
+		case "sourceName":
+			source = attr.Value
+		case "value":
+			value = attr.Value
+		}
+	}
+	key := start + "|" + source
+
+	switch element.Name.Local {
+	case "Correlation":
+		if kind == hkBloodPressure {
+			p.correlation = &bloodPressureSample{key: key, line: line}
+			p.correlation.time, _ = time.Parse(appleHealthDateLayout, start)
+		}
+
+	case "Record":
+		// Heart rates are kept for matching with the blood pressure readings later
+		if kind == hkHeartRate {
+			if sample, ok := parseHeartRate(start, value); ok {
+				p.heartRates = append(p.heartRates, sample)
+			}
+			return
+		} else if kind != hkSystolic && kind != hkDiastolic {
+			return
+		}
+
+		// Blood pressure values belong to the correlation that we are in or, failing
+		// that, to the loose sample with the same start date and source
+		sample := p.correlation
+		if sample == nil {
+			sample = p.loose[key]
+			if sample == nil {
+				sample = &bloodPressureSample{key: key, line: line}
+				sample.time, _ = time.Parse(appleHealthDateLayout, start)
+				p.loose[key] = sample
+			}
+		}
+		if kind == hkSystolic {
+			sample.systolic = value
+		} else {
+			sample.diastolic = value
+		}
+	}
+}
+
+// reading converts a blood pressure sample into a reading, taking the pulse from the
+// nearest heart rate sample if there is one close enough.
+func (p *appleHealthParser) reading(sample *bloodPressureSample) (Reading, error) {
+
+	// The sample must be complete
+	if sample.time.IsZero() {
+		return Reading{}, fmt.Errorf("blood pressure sample on line %d has no valid start date", sample.line)
+	}
+	systolic, err := parseMeasurement("systolic", sample.systolic)
+	if err != nil {
+		return Reading{}, err
+	}
+	diastolic, err := parseMeasurement("diastolic", sample.diastolic)
+	if err != nil {
+		return Reading{}, err
+	}
+	reading := Reading{Time: sample.time, Systolic: systolic, Diastolic: diastolic, Line: sample.line}
+
+	// Find the first heart rate that is not earlier than the reading, then take whichever
+	// of it and the one before is nearer
+	when := sample.time.Unix()
+	index := sort.Search(len(p.heartRates), func(i int) bool { return p.heartRates[i].time >= when })
+	best := int64(heartRateTolerance/time.Second) + 1
+	for _, candidate := range []int{index - 1, index} {
+		if candidate < 0 || candidate >= len(p.heartRates) {
+			continue
+		}
+		distance := p.heartRates[candidate].time - when
+		if distance < 0 {
+			distance = -distance
+		}
+		if distance < best {
+			best = distance
+			reading.Pulse = int(p.heartRates[candidate].value)
+		}
+	}
+	return reading, nil
+}
+
+// parseHeartRate converts the start date and value of a heart rate record into a sample.
+func parseHeartRate(start, value string) (heartRateSample, bool) {
+	when, err := time.Parse(appleHealthDateLayout, start)
+	if err != nil {
+		return heartRateSample{}, false
+	}
+	rate, err := parseMeasurement("heart rate", value)
+	if err != nil {
+		return heartRateSample{}, false
+	}
+	return heartRateSample{time: when.Unix(), value: int32(rate)}, true
+}
+
+// parseMeasurement parses a HealthKit quantity value, which may have a fractional part,
+// rounding it to the nearest whole number.
+func parseMeasurement(name, text string) (int, error) {
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s value %q is not a number", name, text)
+	}
+	return roundToInt(value), nil
+}
+
+// lineTracker is an io.Reader that notes where the lines of its input break, so that a
+// byte offset reached by a decoder reading from it can be turned into a line number.
+type lineTracker struct {
+	r        io.Reader // The source of the input
+	offset   int64     // The number of bytes read so far
+	newlines []int64   // The offsets of the newlines that have been read but not yet passed
+	line     int       // The number of newlines passed so far
+}
+
+// Read reads from the underlying reader, noting the offsets of any newlines.
+func (lt *lineTracker) Read(p []byte) (int, error) {
+	n, err := lt.r.Read(p)
+	for index, b := range p[:n] {
+		if b == '\n' {
+			lt.newlines = append(lt.newlines, lt.offset+int64(index))
+		}
+	}
+	lt.offset += int64(n)
+	return n, err
+}
+
+// lineAt returns the line number, counting from 1, of the given offset. Offsets must be
+// given in ascending order.
+func (lt *lineTracker) lineAt(offset int64) int {
+	for len(lt.newlines) > 0 && lt.newlines[0] < offset {
+		lt.line++
+		lt.newlines = lt.newlines[1:]
+	}
+	return lt.line + 1
+}
@@ -0,0 +1,90 @@
+package dlycsv
+
+// Unit tests for the reading of Apple Health exports.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestAppleHealth converts an Apple Health export, detected from its content.
+func TestAppleHealth(t *testing.T) {
+
+	// Make sure the output file does not exist
+	filePaths := &TestFilePaths{
+		InputPath:    "../testdata/applehealth.xml",
+		OutputPath:   "../testdata/applehealth.out.csv",
+		ExpectedPath: "../testdata/applehealth.expected.csv",
+	}
+	err := removeFile(filePaths.OutputPath)
+	require.Nil(t, err, "could not delete output file: %v", err)
+
+	// Run the target function
+	err = ConvertBloodPressureCSVToDaily(filePaths.InputPath, filePaths.OutputPath, false)
+	require.Nil(t, err, "ConvertBloodPressureCSVToDaily returned an error: %v", err)
+
+	// Confirm that the output obtained matches that expected
+	err = outputIsAsExpected(filePaths)
+	require.Nil(t, err, "output content did not match expected: %v", err)
+}
+
+// TestAppleHealthReadings confirms the detail of the readings taken from an Apple Health
+// export: duplicated samples are only counted once, heart rates are matched by time and
+// incomplete correlations are discarded.
+func TestAppleHealthReadings(t *testing.T) {
+
+	inputFile, err := os.Open("../testdata/applehealth.xml")
+	require.Nil(t, err, "could not open input file: %v", err)
+	defer inputFile.Close()
+
+	readings, rejects, err := parseReadings(context.Background(), inputFile, Options{Format: AppleHealthFormat, Source: "export"})
+	require.Nil(t, err, "parseReadings returned an error: %v", err)
+	require.Len(t, rejects, 1, "the correlation without a diastolic value should be discarded")
+	require.Len(t, readings, 3, "wrong number of readings")
+
+	// Readings are in the order found, with the line on which they started, so the
+	// loose pair of samples comes first; it has no pulse since no heart rate is close enough
+	loose := readings[0]
+	require.Equal(t, 119, loose.Systolic)
+	require.Equal(t, 77, loose.Diastolic)
+	require.Equal(t, 0, loose.Pulse)
+	require.Equal(t, 16, loose.Line)
+
+	// The first correlation takes the heart rate with the same start date
+	first := readings[1]
+	require.Equal(t, "2020-04-26 06:16:43 -0700", first.Time.Format(appleHealthDateLayout))
+	require.Equal(t, 128, first.Systolic)
+	require.Equal(t, 84, first.Diastolic)
+	require.Equal(t, 63, first.Pulse)
+	require.Equal(t, 18, first.Line)
+	require.Equal(t, "export", first.Source)
+
+	// A heart rate less than a minute away is close enough, rounded to a whole number
+	require.Equal(t, 72, readings[2].Pulse)
+}
+
+// TestNotAppleHealth confirms that XML documents other than Apple Health exports, and
+// CSV files that are said to be Apple Health exports, are refused.
+func TestNotAppleHealth(t *testing.T) {
+
+	// Some other XML document
+	_, err := ParseReadings(context.Background(), strings.NewReader("<?xml version=\"1.0\"?>\n<Other/>\n"), Options{})
+	require.Equal(t, FormatError, ClassOf(err), "expected a format error for the wrong XML document")
+
+	// A CSV file
+	input := "Date Time,Systolic,Diastolic,Pulse,Note\n2020-04-26 06:16:43,120,80,60,\n"
+	_, err = ParseReadings(context.Background(), strings.NewReader(input), Options{Format: AppleHealthFormat})
+	require.Equal(t, FormatError, ClassOf(err), "expected a format error for a CSV file")
+
+	// Broken XML
+	_, err = ParseReadings(context.Background(), strings.NewReader("<HealthData><Record</HealthData>"), Options{})
+	require.Equal(t, FormatError, ClassOf(err), "expected a format error for broken XML")
+}
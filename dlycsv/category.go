@@ -0,0 +1,139 @@
+package dlycsv
+
+// Classification of blood pressures into the categories of the published guidelines.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Category is a blood pressure category, in ascending order of severity. Each guideline
+// gives the categories names of its own; Category.String returns the generic names.
+type Category int
+
+// The blood pressure categories.
+const (
+	Normal   Category = iota // Normal blood pressure
+	Elevated                 // Elevated, or high normal, blood pressure
+	Stage1                   // Stage 1, or grade 1, hypertension
+	Stage2                   // Stage 2, or grade 2, hypertension
+	Crisis                   // Hypertensive crisis, or grade 3 hypertension
+)
+
+// The generic names of the categories.
+var categoryNames = []string{"normal", "elevated", "stage 1", "stage 2", "crisis"}
+
+// String returns the generic name of the category.
+func (c Category) String() string {
+	if c < 0 || int(c) >= len(categoryNames) {
+		return fmt.Sprintf("Category(%d)", int(c))
+	}
+	return categoryNames[c]
+}
+
+// Guideline is a table of the pressures at which each blood pressure category starts.
+// A pressure is in the most severe category for which either its systolic or its
+// diastolic pressure reaches the threshold.
+type Guideline struct {
+	Name      string    // The name of the guideline, as accepted by ParseGuideline
+	Systolic  [4]int    // The lowest systolic pressure of each category from Elevated to Crisis
+	Diastolic [4]int    // The lowest diastolic pressure of each category from Elevated to Crisis
+	Labels    [5]string // The names that the guideline gives each category from Normal to Crisis
+}
+
+// AHA2017 is the 2017 American Heart Association / American College of Cardiology guideline.
+// It has no elevated diastolic range: diastolic pressures go straight from normal to stage 1.
+var AHA2017 = &Guideline{
+	Name:      "aha2017",
+	Systolic:  [4]int{120, 130, 140, 181},
+	Diastolic: [4]int{80, 80, 90, 121},
+	Labels:    [5]string{"Normal", "Elevated", "Stage 1 Hypertension", "Stage 2 Hypertension", "Hypertensive Crisis"},
+}
+
+// ESH2023 is the 2023 European Society of Hypertension guideline, whose optimal and normal
+// categories are both taken as Normal.
+var ESH2023 = &Guideline{
+	Name:      "esh2023",
+	Systolic:  [4]int{130, 140, 160, 180},
+	Diastolic: [4]int{85, 90, 100, 110},
+	Labels:    [5]string{"Normal", "High Normal", "Grade 1 Hypertension", "Grade 2 Hypertension", "Grade 3 Hypertension"},
+}
+
+// The colors used to highlight the categories, whatever the guideline.
+var categoryColors = [5]string{"#27ae60", "#f1c40f", "#e67e22", "#c0392b", "#7b241c"}
+
+// The guidelines that can be selected by name.
+var guidelines = []*Guideline{AHA2017, ESH2023}
+
+// ParseGuideline returns the guideline with the given name: aha2017 or esh2023, or
+// simply aha or esh.
+func ParseGuideline(name string) (*Guideline, error) {
+	var names []string
+	for _, guideline := range guidelines {
+		if strings.EqualFold(name, guideline.Name) || strings.EqualFold(name, strings.TrimRight(guideline.Name, "0123456789")) {
+			return guideline, nil
+		}
+		names = append(names, guideline.Name)
+	}
+	return nil, fmt.Errorf("unknown guideline %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+// String returns the name of the guideline.
+func (g *Guideline) String() string {
+	return g.Name
+}
+
+// Classify returns the category of the given pressures.
+func (g *Guideline) Classify(systolic, diastolic int) Category {
+	category := Normal
+	for index := range g.Systolic {
+		if systolic >= g.Systolic[index] || diastolic >= g.Diastolic[index] {
+			category = Category(index + 1)
+		}
+	}
+	return category
+}
+
+// ClassifyReading returns the category of the pressures of a reading.
+func (g *Guideline) ClassifyReading(reading Reading) Category {
+	return g.Classify(reading.Systolic, reading.Diastolic)
+}
+
+// ClassifyMeans returns the category of the given mean pressures, each rounded to the
+// nearest whole number.
+func (g *Guideline) ClassifyMeans(systolic, diastolic float64) Category {
+	return g.Classify(roundToInt(systolic), roundToInt(diastolic))
+}
+
+// ClassifyStats returns the category of the mean pressures of a set of readings.
+func (g *Guideline) ClassifyStats(stats DailyStats) Category {
+	return g.ClassifyMeans(stats.Systolic.Mean, stats.Diastolic.Mean)
+}
+
+// Label returns the name that the guideline gives the category.
+func (g *Guideline) Label(category Category) string {
+	if category < Normal || category > Crisis {
+		return category.String()
+	}
+	return g.Labels[category]
+}
+
+// color returns the color used to highlight the category in charts and reports.
+func (c Category) color() string {
+	if c < Normal || c > Crisis {
+		return "#999"
+	}
+	return categoryColors[c]
+}
+
+// guidelineOrDefault returns the guideline selected by the options, AHA2017 if none is.
+func guidelineOrDefault(opts Options) *Guideline {
+	if opts.Guideline == nil {
+		return AHA2017
+	}
+	return opts.Guideline
+}
@@ -0,0 +1,76 @@
+package dlycsv
+
+// Unit tests for the classification of blood pressures by guideline.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestClassifyAHA confirms the category boundaries of the AHA 2017 guideline.
+func TestClassifyAHA(t *testing.T) {
+	require.Equal(t, Normal, AHA2017.Classify(119, 79))
+	require.Equal(t, Elevated, AHA2017.Classify(125, 79))
+	require.Equal(t, Stage1, AHA2017.Classify(125, 80), "a diastolic of 80 alone should be stage 1")
+	require.Equal(t, Stage1, AHA2017.Classify(130, 70))
+	require.Equal(t, Stage2, AHA2017.Classify(140, 70))
+	require.Equal(t, Stage2, AHA2017.Classify(120, 90), "a diastolic of 90 alone should be stage 2")
+	require.Equal(t, Crisis, AHA2017.Classify(181, 100))
+	require.Equal(t, Crisis, AHA2017.Classify(150, 121))
+	require.Equal(t, "Stage 1 Hypertension", AHA2017.Label(Stage1))
+}
+
+// TestClassifyESH confirms the category boundaries of the ESH 2023 guideline.
+func TestClassifyESH(t *testing.T) {
+	require.Equal(t, Normal, ESH2023.Classify(129, 84))
+	require.Equal(t, Elevated, ESH2023.Classify(135, 84))
+	require.Equal(t, Elevated, ESH2023.Classify(125, 85))
+	require.Equal(t, Stage1, ESH2023.Classify(140, 70))
+	require.Equal(t, Stage2, ESH2023.Classify(150, 100))
+	require.Equal(t, Crisis, ESH2023.Classify(180, 80))
+	require.Equal(t, "High Normal", ESH2023.Label(Elevated))
+	require.Equal(t, Stage1, ESH2023.ClassifyMeans(139.6, 80.2), "means should be rounded before classifying")
+}
+
+// TestParseGuideline confirms that guidelines can be found by their names.
+func TestParseGuideline(t *testing.T) {
+	for name, expected := range map[string]*Guideline{"aha2017": AHA2017, "AHA": AHA2017, "esh": ESH2023, "esh2023": ESH2023} {
+		guideline, err := ParseGuideline(name)
+		require.Nil(t, err, "%q should have been accepted: %v", name, err)
+		require.Equal(t, expected, guideline)
+	}
+	_, err := ParseGuideline("jnc7")
+	require.NotNil(t, err, "an unknown guideline should have been refused")
+}
+
+// TestClassifyColumns confirms that each reading and each day are given a category column.
+func TestClassifyColumns(t *testing.T) {
+	var output bytes.Buffer
+	err := WriteDaily(&output, GroupByDay(statsReadings()), Options{Classify: true, Policy: KeepFirst})
+	require.Nil(t, err, "WriteDaily returned an error: %v", err)
+	lines := strings.Split(output.String(), "\n")
+	require.Equal(t, "Date Time (first),Systolic (first),Diastolic (first),Pulse (first),Note (first),"+
+		"Category (first),Daily Category", lines[0])
+	require.Equal(t, "2020-05-01 07:00:00,120,80,60,,Stage 1 Hypertension,Stage 1 Hypertension", lines[1],
+		"the daily category should come from the mean of every reading")
+
+	// The guideline of the options should be followed
+	output.Reset()
+	err = WriteDaily(&output, GroupByDay(statsReadings()), Options{Classify: true, Guideline: ESH2023, Policy: KeepFirst})
+	require.Nil(t, err, "WriteDaily returned an error: %v", err)
+	require.Equal(t, "2020-05-01 07:00:00,120,80,60,,Normal,Normal", strings.Split(output.String(), "\n")[1])
+}
+
+// TestClassifyChart confirms that classified chart points are outlined in their category color.
+func TestClassifyChart(t *testing.T) {
+	svg := renderChart(GroupByDay(statsReadings()), Options{Classify: true})
+	require.Contains(t, svg, Stage2.color(), "the stage 2 threshold should be drawn")
+	require.Contains(t, svg, "Stage 1 Hypertension</title>", "points should be labelled with their category")
+}
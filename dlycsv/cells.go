@@ -0,0 +1,86 @@
+package dlycsv
+
+// Typed output values, so that output formats that understand types, such as
+// spreadsheets, can keep numbers as numbers and times as times.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"strconv"
+	"time"
+)
+
+// The layouts of times, and of dates, written as text.
+const (
+	timestampLayout = "2006-01-02 15:04:05"
+	dateLayout      = "2006-01-02"
+)
+
+// cellKind identifies the type of value held by a cell.
+type cellKind int
+
+// The kinds of cell.
+const (
+	blankCell  cellKind = iota // The cell has no value
+	textCell                   // The cell holds text
+	numberCell                 // The cell holds a number
+	timeCell                   // The cell holds a date and time
+	dateCell                   // The cell holds a date without a time
+)
+
+// cell is a single typed value of the output.
+type cell struct {
+	kind   cellKind  // The type of value held
+	text   string    // The text of a text cell
+	number float64   // The value of a number cell
+	time   time.Time // The value of a time cell
+}
+
+// textValue returns a text cell, or a blank cell if the text is empty.
+func textValue(text string) cell {
+	if text == "" {
+		return cell{}
+	}
+	return cell{kind: textCell, text: text}
+}
+
+// intValue returns a number cell holding the given whole number.
+func intValue(value int) cell {
+	return cell{kind: numberCell, number: float64(value)}
+}
+
+// timeValue returns a time cell.
+func timeValue(t time.Time) cell {
+	return cell{kind: timeCell, time: t}
+}
+
+// dateValue returns a date cell.
+func dateValue(t time.Time) cell {
+	return cell{kind: dateCell, time: t}
+}
+
+// String returns the text of the cell, as written to CSV output.
+func (c cell) String() string {
+	switch c.kind {
+	case textCell:
+		return c.text
+	case numberCell:
+		return strconv.FormatFloat(c.number, 'f', -1, 64)
+	case timeCell:
+		return c.time.Format(timestampLayout)
+	case dateCell:
+		return c.time.Format(dateLayout)
+	}
+	return ""
+}
+
+// cellsToStrings returns the text of each of the given cells.
+func cellsToStrings(cells []cell) []string {
+	record := make([]string, len(cells))
+	for index, c := range cells {
+		record[index] = c.String()
+	}
+	return record
+}
@@ -0,0 +1,293 @@
+package dlycsv
+
+// Rendering of the daily readings as an SVG chart, in pure Go so that charts can be
+// produced on machines without any graphical tools.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// The dimensions of the chart, in pixels.
+const (
+	chartWidth   = 960
+	chartHeight  = 540
+	marginLeft   = 60
+	marginRight  = 60
+	marginTop    = 50
+	marginBottom = 70
+	plotWidth    = chartWidth - marginLeft - marginRight
+	plotHeight   = chartHeight - marginTop - marginBottom
+)
+
+// The colors of the chart series.
+const (
+	systolicColor  = "#c0392b"
+	diastolicColor = "#2471a3"
+	pulseColor     = "#7d3c98"
+)
+
+// WriteSVG writes the given daily groups to w as an SVG chart. Systolic and diastolic
+// pressures are drawn as bands spanning each day's lowest to highest reading, with a line
+// through the daily means and a point for every reading at its time of day. The pulse is
+// drawn against a secondary axis on the right, and the thresholds of the stage 1 and stage 2
+// categories of the guideline selected by the options are drawn as dashed reference lines.
+// If the options ask for readings to be classified, each point is outlined in the color of
+// its category. The policy and slots of the options select the readings of each day that
+// are drawn, just as they select those written to the daily CSV output.
+func WriteSVG(w io.Writer, groups []DailyGroup, opts Options) error {
+	_, err := io.WriteString(w, xmlDeclaration+renderChart(groups, opts))
+	if err != nil {
+		return classifiedErrorf(OutputError, "failed to write chart to output file: %w", err)
+	}
+	return nil
+}
+
+// The XML declaration that starts a standalone SVG file.
+const xmlDeclaration = `<?xml version="1.0" encoding="UTF-8"?>` + "\n"
+
+// chart holds the scales of a chart while it is being drawn.
+type chart struct {
+	sb           strings.Builder // The SVG markup drawn so far
+	guideline    *Guideline      // The guideline whose thresholds are drawn
+	classify     bool            // True if points are outlined in the color of their category
+	first        time.Time       // Midnight at the start of the first day charted
+	dayStart     time.Duration   // The time of day at which each day starts
+	days         int             // The number of days spanned by the chart
+	pressureLow  float64         // The pressure at the bottom of the chart
+	pressureHigh float64         // The pressure at the top of the chart
+	pulseLow     float64         // The pulse rate at the bottom of the chart
+	pulseHigh    float64         // The pulse rate at the top of the chart
+}
+
+// dayPlot is the readings drawn for one day, with the statistics of their pressures.
+type dayPlot struct {
+	date      time.Time // Midnight at the start of the day
+	readings  []Reading // The readings drawn for the day
+	systolic  Range     // The spread of the systolic pressures of the day
+	diastolic Range     // The spread of the diastolic pressures of the day
+}
+
+// renderChart returns the SVG markup of a chart of the given daily groups, without any
+// XML declaration so that it can also be embedded in HTML.
+func renderChart(groups []DailyGroup, opts Options) string {
+
+	// Gather the readings to be drawn for each day
+	layout := newDailyLayout(groups, opts)
+	var plots []dayPlot
+	for _, group := range groups {
+		plot := dayPlot{date: group.Date}
+		for _, readings := range layout.columnSets(group) {
+			plot.readings = append(plot.readings, readings...)
+		}
+		if len(plot.readings) > 0 {
+			plot.systolic, plot.diastolic, _ = readingRanges(plot.readings)
+			plots = append(plots, plot)
+		}
+	}
+
+	c := &chart{guideline: guidelineOrDefault(opts), classify: opts.Classify, dayStart: opts.DayStart}
+	fmt.Fprintf(&c.sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`,
+		chartWidth, chartHeight, chartWidth, chartHeight)
+	c.sb.WriteString(`<rect width="100%" height="100%" fill="white"/>`)
+	c.sb.WriteString(`<text x="480" y="24" text-anchor="middle" font-size="16">Blood Pressure</text>`)
+
+	// There is not much to draw without readings
+	if len(plots) == 0 {
+		c.sb.WriteString(`<text x="480" y="270" text-anchor="middle">No readings</text></svg>`)
+		return c.sb.String()
+	}
+
+	// Decide the scales, then draw the chart from the back to the front
+	c.setScales(plots)
+	c.drawAxes()
+	c.drawThresholds()
+	c.drawBand(plots, func(p dayPlot) Range { return p.systolic }, systolicColor)
+	c.drawBand(plots, func(p dayPlot) Range { return p.diastolic }, diastolicColor)
+	c.drawPulse(plots)
+	c.drawPoints(plots, func(r Reading) int { return r.Systolic }, systolicColor)
+	c.drawPoints(plots, func(r Reading) int { return r.Diastolic }, diastolicColor)
+	c.drawLegend()
+	c.sb.WriteString(`</svg>`)
+	return c.sb.String()
+}
+
+// readingRanges returns the spread of the systolic, diastolic and pulse values of the
+// given readings, leaving out pulses that were not recorded.
+func readingRanges(readings []Reading) (systolic, diastolic, pulse Range) {
+	var systolicAcc, diastolicAcc, pulseAcc rangeAccumulator
+	for _, reading := range readings {
+		systolicAcc.add(reading.Systolic)
+		diastolicAcc.add(reading.Diastolic)
+		if reading.Pulse != 0 {
+			pulseAcc.add(reading.Pulse)
+		}
+	}
+	return systolicAcc.result(), diastolicAcc.result(), pulseAcc.result()
+}
+
+// setScales sizes the axes to fit the readings and the guideline thresholds.
+func (c *chart) setScales(plots []dayPlot) {
+
+	// The time axis runs from the start of the first day to the end of the last
+	c.first = plots[0].date
+	c.days = daysBetween(c.first, plots[len(plots)-1].date) + 1
+
+	// The pressure axis always shows the thresholds, the pulse axis a sensible resting range
+	c.pressureLow, c.pressureHigh = 60, float64(c.guideline.Systolic[Stage2-1]+10)
+	c.pulseLow, c.pulseHigh = 40, 100
+	for _, plot := range plots {
+		_, _, pulse := readingRanges(plot.readings)
+		c.pressureLow = math.Min(c.pressureLow, float64(plot.diastolic.Min))
+		c.pressureHigh = math.Max(c.pressureHigh, float64(plot.systolic.Max))
+		if pulse.Max > 0 {
+			c.pulseLow = math.Min(c.pulseLow, float64(pulse.Min))
+			c.pulseHigh = math.Max(c.pulseHigh, float64(pulse.Max))
+		}
+	}
+	c.pressureLow, c.pressureHigh = math.Floor(c.pressureLow/20)*20, math.Ceil(c.pressureHigh/20)*20
+	c.pulseLow, c.pulseHigh = math.Floor(c.pulseLow/20)*20, math.Ceil(c.pulseHigh/20)*20
+}
+
+// daysBetween returns the number of calendar days from one date to another.
+func daysBetween(from, to time.Time) int {
+	fromYear, fromMonth, fromDay := from.Date()
+	toYear, toMonth, toDay := to.Date()
+	start := time.Date(fromYear, fromMonth, fromDay, 0, 0, 0, 0, time.UTC)
+	end := time.Date(toYear, toMonth, toDay, 0, 0, 0, 0, time.UTC)
+	return int(end.Sub(start).Hours() / 24)
+}
+
+// x returns the horizontal position of the given time since the start of the given day.
+func (c *chart) x(date time.Time, offset time.Duration) float64 {
+	position := float64(daysBetween(c.first, date)) + offset.Hours()/24
+	return marginLeft + position/float64(c.days)*plotWidth
+}
+
+// sinceDayStart returns the clock time elapsed between the start of the given day and the
+// given time, which is after midnight of the following date if the day starts after midnight.
+func (c *chart) sinceDayStart(date, t time.Time) time.Duration {
+	return time.Duration(daysBetween(date, t))*24*time.Hour + timeOfDay(t) - c.dayStart
+}
+
+// yPressure returns the vertical position of the given pressure.
+func (c *chart) yPressure(value float64) float64 {
+	return marginTop + (c.pressureHigh-value)/(c.pressureHigh-c.pressureLow)*plotHeight
+}
+
+// yPulse returns the vertical position of the given pulse rate.
+func (c *chart) yPulse(value float64) float64 {
+	return marginTop + (c.pulseHigh-value)/(c.pulseHigh-c.pulseLow)*plotHeight
+}
+
+// drawAxes draws the plot frame, the grid, and the labels of the three axes.
+func (c *chart) drawAxes() {
+
+	// The pressure axis on the left, with its grid lines, and the pulse axis on the right
+	for value := c.pressureLow; value <= c.pressureHigh; value += 20 {
+		y := c.yPressure(value)
+		fmt.Fprintf(&c.sb, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#e5e5e5"/>`, marginLeft, y, marginLeft+plotWidth, y)
+		fmt.Fprintf(&c.sb, `<text x="%d" y="%.1f" text-anchor="end" dy="4">%.0f</text>`, marginLeft-6, y, value)
+	}
+	for value := c.pulseLow; value <= c.pulseHigh; value += 20 {
+		fmt.Fprintf(&c.sb, `<text x="%d" y="%.1f" dy="4" fill="%s">%.0f</text>`, marginLeft+plotWidth+6, c.yPulse(value), pulseColor, value)
+	}
+	fmt.Fprintf(&c.sb, `<text transform="translate(16 %d) rotate(-90)" text-anchor="middle">mmHg</text>`, marginTop+plotHeight/2)
+	fmt.Fprintf(&c.sb, `<text transform="translate(%d %d) rotate(90)" text-anchor="middle" fill="%s">Pulse (bpm)</text>`,
+		chartWidth-14, marginTop+plotHeight/2, pulseColor)
+
+	// The date axis along the bottom, labelling no more than a dozen or so days
+	step := (c.days + 11) / 12
+	for day := 0; day < c.days; day += step {
+		date := c.first.AddDate(0, 0, day)
+		x := c.x(date, 12*time.Hour)
+		fmt.Fprintf(&c.sb, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" stroke="#999"/>`, x, marginTop+plotHeight, x, marginTop+plotHeight+5)
+		fmt.Fprintf(&c.sb, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`, x, marginTop+plotHeight+20, date.Format("Jan 2"))
+	}
+	fmt.Fprintf(&c.sb, `<rect x="%d" y="%d" width="%d" height="%d" fill="none" stroke="#999"/>`, marginLeft, marginTop, plotWidth, plotHeight)
+}
+
+// drawThresholds draws the systolic and diastolic thresholds of the stage 1 and stage 2
+// categories of the guideline as dashed lines across the plot.
+func (c *chart) drawThresholds() {
+	for _, category := range []Category{Stage1, Stage2} {
+		for _, value := range []int{c.guideline.Systolic[category-1], c.guideline.Diastolic[category-1]} {
+			y := c.yPressure(float64(value))
+			fmt.Fprintf(&c.sb, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="%s" stroke-dasharray="6 4"/>`,
+				marginLeft, y, marginLeft+plotWidth, y, category.color())
+			fmt.Fprintf(&c.sb, `<text x="%d" y="%.1f" dy="-3" font-size="10" fill="%s">%s %d</text>`,
+				marginLeft+4, y, category.color(), escapeXML(c.guideline.Label(category)), value)
+		}
+	}
+}
+
+// drawBand draws the band between the lowest and highest pressure of each day, with a
+// line through the daily means.
+func (c *chart) drawBand(plots []dayPlot, spread func(dayPlot) Range, color string) {
+
+	// Go forwards along the highs and back along the lows
+	var outline, means []string
+	for _, plot := range plots {
+		outline = append(outline, fmt.Sprintf("%.1f,%.1f", c.x(plot.date, 12*time.Hour), c.yPressure(float64(spread(plot).Max))))
+		means = append(means, fmt.Sprintf("%.1f,%.1f", c.x(plot.date, 12*time.Hour), c.yPressure(spread(plot).Mean)))
+	}
+	for index := len(plots) - 1; index >= 0; index-- {
+		plot := plots[index]
+		outline = append(outline, fmt.Sprintf("%.1f,%.1f", c.x(plot.date, 12*time.Hour), c.yPressure(float64(spread(plot).Min))))
+	}
+	fmt.Fprintf(&c.sb, `<polygon points="%s" fill="%s" fill-opacity="0.15" stroke="none"/>`, strings.Join(outline, " "), color)
+	fmt.Fprintf(&c.sb, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"/>`, strings.Join(means, " "), color)
+}
+
+// drawPulse draws the pulse of every reading that has one against the secondary axis.
+func (c *chart) drawPulse(plots []dayPlot) {
+	var points []string
+	for _, plot := range plots {
+		for _, reading := range plot.readings {
+			if reading.Pulse != 0 {
+				points = append(points, fmt.Sprintf("%.1f,%.1f", c.x(plot.date, c.sinceDayStart(plot.date, reading.Time)), c.yPulse(float64(reading.Pulse))))
+			}
+		}
+	}
+	if len(points) > 0 {
+		fmt.Fprintf(&c.sb, `<polyline points="%s" fill="none" stroke="%s" stroke-width="1" stroke-dasharray="2 3"/>`, strings.Join(points, " "), pulseColor)
+	}
+}
+
+// drawPoints draws a point for every reading at its time of day, outlined in the color of
+// its category if readings are being classified.
+func (c *chart) drawPoints(plots []dayPlot, value func(Reading) int, color string) {
+	for _, plot := range plots {
+		for _, reading := range plot.readings {
+			outline, title := "", fmt.Sprintf("%s %d/%d", reading.Time.Format(timestampLayout), reading.Systolic, reading.Diastolic)
+			if c.classify {
+				category := c.guideline.ClassifyReading(reading)
+				outline = fmt.Sprintf(` stroke="%s" stroke-width="2"`, category.color())
+				title += " " + c.guideline.Label(category)
+			}
+			fmt.Fprintf(&c.sb, `<circle cx="%.1f" cy="%.1f" r="3" fill="%s"%s><title>%s</title></circle>`,
+				c.x(plot.date, c.sinceDayStart(plot.date, reading.Time)), c.yPressure(float64(value(reading))), color, outline, escapeXML(title))
+		}
+	}
+}
+
+// drawLegend names the series beneath the plot.
+func (c *chart) drawLegend() {
+	y := chartHeight - 20
+	for index, entry := range []struct{ name, color string }{
+		{"Systolic", systolicColor},
+		{"Diastolic", diastolicColor},
+		{"Pulse", pulseColor},
+	} {
+		x := marginLeft + index*120
+		fmt.Fprintf(&c.sb, `<rect x="%d" y="%d" width="12" height="12" fill="%s"/>`, x, y-10, entry.color)
+		fmt.Fprintf(&c.sb, `<text x="%d" y="%d">%s</text>`, x+18, y, entry.name)
+	}
+}
@@ -0,0 +1,84 @@
+package dlycsv
+
+// Unit tests for the SVG chart output.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestConvertToSVG charts the happy path input and checks the content of the chart.
+func TestConvertToSVG(t *testing.T) {
+
+	inputFile, err := os.Open("../testdata/happypath.in.csv")
+	require.Nil(t, err, "could not open input file: %v", err)
+	defer inputFile.Close()
+
+	// Chart into a buffer
+	var output bytes.Buffer
+	err = Convert(context.Background(), inputFile, &output, Options{Output: SVGOutput})
+	require.Nil(t, err, "Convert returned an error: %v", err)
+	svg := output.String()
+
+	// The chart must be well formed XML
+	decoder := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		require.Nil(t, err, "chart is not well formed: %v", err)
+	}
+
+	// There is a point for each systolic and diastolic reading, and a band for each
+	require.Equal(t, 44, strings.Count(svg, "<circle"), "wrong number of points")
+	require.Equal(t, 2, strings.Count(svg, "<polygon"), "wrong number of bands")
+	require.Contains(t, svg, "<title>2020-04-26 06:16:43 97/68</title>")
+
+	// The thresholds and axes are labelled
+	require.Contains(t, svg, ">Stage 2 Hypertension 140<")
+	require.Contains(t, svg, ">Pulse (bpm)<")
+	require.Contains(t, svg, ">Apr 26<")
+}
+
+// TestChartPolicy confirms that the policy selects the readings that are charted.
+func TestChartPolicy(t *testing.T) {
+	readings := []Reading{
+		{Time: time.Date(2020, 5, 1, 7, 0, 0, 0, time.UTC), Systolic: 120, Diastolic: 80, Pulse: 60},
+		{Time: time.Date(2020, 5, 1, 19, 0, 0, 0, time.UTC), Systolic: 130, Diastolic: 84, Pulse: 64},
+	}
+	svg := renderChart(GroupByDay(readings), Options{Policy: KeepLast})
+	require.Equal(t, 2, strings.Count(svg, "<circle"), "only the last reading should be charted")
+	require.Contains(t, svg, "<title>2020-05-01 19:00:00 130/84</title>")
+}
+
+// TestEmptyChart confirms that a chart with no readings says so.
+func TestEmptyChart(t *testing.T) {
+	var output bytes.Buffer
+	err := WriteSVG(&output, nil, Options{})
+	require.Nil(t, err, "WriteSVG returned an error: %v", err)
+	require.Contains(t, output.String(), "No readings")
+}
+
+// TestDaysBetween confirms that calendar days are counted across daylight saving changes.
+func TestDaysBetween(t *testing.T) {
+	zone, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+	from := time.Date(2020, 3, 7, 0, 0, 0, 0, zone)
+	to := time.Date(2020, 3, 9, 0, 0, 0, 0, zone)
+	require.Equal(t, 2, daysBetween(from, to))
+}
@@ -0,0 +1,294 @@
+package dlycsv
+
+// A column mapping input format: the engine shared by the input formats whose CSV
+// files have one reading per record, in columns identified by their header names.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The fields of a reading that a CSV column can hold.
+const (
+	fieldDateTime = iota
+	fieldDate
+	fieldTime
+	fieldSystolic
+	fieldDiastolic
+	fieldPulse
+	fieldNote
+	fieldIrregularHeartbeat
+	fieldBodyMovement
+	fieldPosition
+	fieldZone
+	fieldIgnored
+	fieldCount
+)
+
+// The layouts accepted for combined date time values unless a format says otherwise,
+// tried in order. Dates written with slashes are taken to be month first. Only the ISO
+// layouts may carry an offset from UTC.
+var defaultDateTimeLayouts = []string{
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 -07:00",
+	"2006-01-02 15:04:05 -0700",
+	"Jan 02 2006 15:04:05",
+	"Jan 2 2006 15:04",
+	"Jan 2, 2006 15:04:05",
+	"Jan 2, 2006 15:04",
+	"Jan 2, 2006 3:04 PM",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05",
+	"2006/01/02 15:04:05",
+	"2006/01/02 15:04",
+	"1/2/2006 15:04:05",
+	"1/2/2006 15:04",
+	"1/2/2006 3:04 PM",
+	"1/2/2006 3:04:05 PM",
+}
+
+// The layouts accepted for separate date values unless a format says otherwise,
+// tried in order.
+var defaultDateLayouts = []string{
+	"Jan 02 2006",
+	"Jan 2, 2006",
+	"2006-01-02",
+	"2006/01/02",
+	"1/2/2006",
+}
+
+// The layouts accepted for separate time values unless a format says otherwise,
+// tried in order.
+var defaultTimeLayouts = []string{
+	"15:04:05",
+	"15:04",
+	"3:04 PM",
+	"3:04:05 PM",
+}
+
+// Matches parenthesized units, such as "(mmHg)", in column names.
+var unitsPattern = regexp.MustCompile(`\([^)]*\)`)
+
+// columnFormat is an InputFormat that recognizes a CSV file by the names of its columns.
+type columnFormat struct {
+	name            string         // The name of the format
+	columns         map[string]int // Normalized column names mapped to the field that each column holds
+	dateTimeLayouts []string       // The layouts of combined date time values, nil for the defaults
+	dateLayouts     []string       // The layouts of separate date values, nil for the defaults
+	timeLayouts     []string       // The layouts of separate time values, nil for the defaults
+	requirePulse    bool           // True if the pulse column must be present
+	ignoreUnknown   bool           // True if columns that the format does not know are ignored rather than refused
+}
+
+// Name returns the name of the format.
+func (f *columnFormat) Name() string {
+	return f.name
+}
+
+// Detect examines a header record and, if it is one of ours, returns a RecordParser for
+// the records that follow it. Every column must be one that the format knows, unless
+// the format ignores unknown columns, and the date and time, systolic and diastolic
+// columns must all be present, as must the pulse column if the format requires it.
+func (f *columnFormat) Detect(header []string) (RecordParser, bool) {
+
+	// Start with every field missing
+	parser := &columnParser{format: f}
+	for field := range parser.columns {
+		parser.columns[field] = -1
+	}
+
+	// Match each column name to the field that it holds
+	for index, name := range header {
+		field, ok := f.columns[normalizeColumnName(name)]
+		if !ok && f.ignoreUnknown {
+			continue
+		} else if !ok || (field != fieldIgnored && parser.columns[field] >= 0) {
+			return nil, false
+		}
+		parser.columns[field] = index
+	}
+
+	// Check that we have the essential fields
+	var has [fieldCount]bool
+	for field, index := range parser.columns {
+		has[field] = index >= 0
+	}
+	if !f.hasEssentialFields(has) {
+		return nil, false
+	}
+	return parser.parse, true
+}
+
+// hasEssentialFields returns true if the fields marked as present include the date and
+// time, systolic and diastolic fields, and the pulse field if the format requires it.
+func (f *columnFormat) hasEssentialFields(has [fieldCount]bool) bool {
+	hasDateTime := has[fieldDateTime] || (has[fieldDate] && has[fieldTime])
+	return hasDateTime && has[fieldSystolic] && has[fieldDiastolic] && (has[fieldPulse] || !f.requirePulse)
+}
+
+// normalizeColumnName reduces a column name to lower case words without units,
+// punctuation or any byte order mark.
+func normalizeColumnName(name string) string {
+	name = strings.TrimPrefix(name, "\ufeff")
+	name = unitsPattern.ReplaceAllString(name, " ")
+	name = strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' || r == '?' || r == ':' || r == '"' {
+			return ' '
+		}
+		return r
+	}, name)
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// columnParser parses the records that follow a header recognized by a columnFormat.
+type columnParser struct {
+	format  *columnFormat   // The format that recognized the header
+	columns [fieldCount]int // The column in which each field is found, -1 if the field is not present
+}
+
+// parse converts the fields of a CSV record into a Reading.
+func (p *columnParser) parse(fields []string) (Reading, error) {
+
+	// Check that the record has all of the columns that we need
+	for field, index := range p.columns {
+		if index >= len(fields) && field != fieldIgnored {
+			return Reading{}, errors.New("too few fields")
+		}
+	}
+
+	// Parse the date and time
+	var reading Reading
+	var err error
+	if index := p.columns[fieldDateTime]; index >= 0 {
+		reading.Time, err = parseWithLayouts(layoutsOr(p.format.dateTimeLayouts, defaultDateTimeLayouts), fields[index])
+	} else {
+		reading.Time, err = p.parseDateAndTime(fields[p.columns[fieldDate]], fields[p.columns[fieldTime]])
+	}
+	if err != nil {
+		return Reading{}, err
+	}
+
+	// The pressures must be integers
+	if reading.Systolic, err = parseInteger("systolic", fields[p.columns[fieldSystolic]]); err != nil {
+		return Reading{}, err
+	}
+	if reading.Diastolic, err = parseInteger("diastolic", fields[p.columns[fieldDiastolic]]); err != nil {
+		return Reading{}, err
+	}
+
+	// As must the pulse, if we have one
+	if index := p.columns[fieldPulse]; index >= 0 {
+		if reading.Pulse, err = parseInteger("pulse", fields[index]); err != nil {
+			return Reading{}, err
+		}
+	}
+
+	// The remaining fields are optional
+	if index := p.columns[fieldNote]; index >= 0 {
+		reading.Note = fields[index]
+	}
+	if index := p.columns[fieldIrregularHeartbeat]; index >= 0 {
+		reading.IrregularHeartbeat = parseDetection(fields[index])
+	}
+	if index := p.columns[fieldBodyMovement]; index >= 0 {
+		reading.BodyMovement = parseDetection(fields[index])
+	}
+	if index := p.columns[fieldPosition]; index >= 0 {
+		reading.Position = strings.TrimSpace(fields[index])
+	}
+
+	// A time zone column gives the zone of a clock reading that has no offset of its own
+	if index := p.columns[fieldZone]; index >= 0 && strings.TrimSpace(fields[index]) != "" && reading.Time.Location() == time.UTC {
+		zone, err := parseZoneColumn(fields[index])
+		if err != nil {
+			return Reading{}, err
+		}
+		reading.Time = wallClockIn(reading.Time, zone)
+	}
+	return reading, nil
+}
+
+// parseZoneColumn parses the value of a time zone column with ParseZone, accepting the
+// display names of the form "(GMT+09:00) Osaka, Sapporo, Tokyo" by their offset alone. A
+// zone of UTC is given as explicitUTC, so that it is not mistaken for no zone at all.
+func parseZoneColumn(text string) (*time.Location, error) {
+	text = strings.TrimSpace(text)
+	if end := strings.Index(text, ")"); strings.HasPrefix(text, "(") && end > 0 {
+		text = text[1:end]
+	}
+	zone, err := ParseZone(text)
+	if zone == time.UTC {
+		zone = explicitUTC
+	}
+	return zone, err
+}
+
+// parseDateAndTime parses separate date and time values into a single time.
+func (p *columnParser) parseDateAndTime(dateText, timeText string) (time.Time, error) {
+	date, err := parseWithLayouts(layoutsOr(p.format.dateLayouts, defaultDateLayouts), dateText)
+	if err != nil {
+		return time.Time{}, err
+	}
+	clock, err := parseWithLayouts(layoutsOr(p.format.timeLayouts, defaultTimeLayouts), timeText)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return date.Add(timeOfDay(clock)), nil
+}
+
+// layoutsOr returns the given layouts, or the defaults if there are none.
+func layoutsOr(layouts, defaults []string) []string {
+	if len(layouts) == 0 {
+		return defaults
+	}
+	return layouts
+}
+
+// explicitUTC is the zone of times that were read with an offset of zero, kept apart from
+// time.UTC, the zone of times that were read without an offset.
+var explicitUTC = time.FixedZone("UTC", 0)
+
+// parseWithLayouts parses the given text with the first of the layouts that fits it.
+func parseWithLayouts(layouts []string, text string) (time.Time, error) {
+	text = strings.TrimSpace(text)
+	for _, layout := range layouts {
+		if parsed, err := time.Parse(layout, text); err == nil {
+			if parsed.Location() == time.UTC && (strings.Contains(layout, "Z07") || strings.Contains(layout, "-07")) {
+				parsed = parsed.In(explicitUTC)
+			}
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a recognized date or time", text)
+}
+
+// parseInteger parses the value of the named field as an integer.
+func parseInteger(name, text string) (int, error) {
+	value, err := strconv.Atoi(strings.TrimSpace(text))
+	if err != nil {
+		return 0, fmt.Errorf("%s value %q is not a whole number", name, text)
+	}
+	return value, nil
+}
+
+// parseDetection interprets the value of an indicator column, such as irregular heartbeat.
+// Blank and dash values are taken to mean that the indicator was not recorded.
+func parseDetection(text string) Detection {
+	switch strings.ToLower(strings.TrimSpace(text)) {
+	case "", "-", "--", "n/a":
+		return NotRecorded
+	case "no", "n", "false", "0", "off", "not detected", "none":
+		return NotDetected
+	}
+	return Detected
+}
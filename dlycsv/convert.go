@@ -0,0 +1,85 @@
+package dlycsv
+
+// The io.Reader / io.Writer based entry point of the dlycsv package, for use
+// by callers that do not want to touch the filesystem.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Options control how Convert processes blood pressure readings. The zero value
+// selects the default behavior.
+type Options struct {
+	Source string       // The name recorded as the source of each reading, such as the input file path
+	Format InputFormat  // The format of the input, nil to detect it from the header record
+	Slots  []Slot       // Named time of day ranges giving each reading a fixed set of columns, if any
+	Policy Policy       // How several readings in the same slot, or day if there are no slots, are resolved
+	Output OutputFormat // The format in which the daily readings are written
+	Stats  bool         // True to append per-day summary statistics columns, computed before the policy is applied
+
+	Lenient  bool         // True to skip records that cannot be read, such as those with too many fields, rather than fail
+	Rejected func(Reject) // Called with each input record that is rejected rather than converted into a reading, if not nil
+
+	Limits      *Limits      // The plausible ranges of reading values, nil for DefaultLimits
+	Implausible Plausibility // What is done with readings that are not plausible
+
+	InputZone   *time.Location // The time zone of times read without an offset, nil for UTC
+	DisplayZone *time.Location // The time zone in which readings are gathered into days and written, nil for that of each reading
+
+	DayStart time.Duration // The time of day at which each day starts, before 12:00; readings taken before it join the day before
+
+	From time.Time // Readings taken on days before the day of From are left out, zero to keep the earliest
+	To   time.Time // Readings taken on days after the day of To are left out, zero to keep the latest
+
+	GroupBy Period // The period that each line of the output aggregates, one line per day with every reading by default
+	Rolling []int  // The lengths, in days, of the windows of the rolling average columns, such as 7 and 30, if any
+
+	FillGaps bool      // True to write an empty day, with a leading date column, for each day without readings
+	FillFrom time.Time // The first day of the calendar that FillGaps completes, zero for From or else the first day with readings
+	FillTo   time.Time // The last day of the calendar that FillGaps completes, zero for To or else the last day with readings
+
+	Classify  bool       // True to add the category of each reading, and of each day's mean, to the daily output
+	Guideline *Guideline // The guideline that categories and chart thresholds follow, nil for AHA2017
+}
+
+// Convert reads blood pressure CSV data from the given reader, sorts the data, then gathers
+// lines that are for the same day into a single line, writing the results to the given
+// writer in the output format selected by the options. Nothing is written if the input is
+// found not to be blood pressure CSV data. Records that are not valid readings are skipped
+// and passed to the Rejected handler of the options. Readings are not merged, since there
+// is only one input; ConvertFiles merges the readings of several.
+//
+// Reading stops early, returning the context error, if the context is cancelled.
+func Convert(ctx context.Context, r io.Reader, w io.Writer, opts Options) error {
+
+	// Parse the input into readings
+	readings, rejects, err := parseReadings(ctx, r, opts)
+	if err != nil {
+		return err
+	}
+	reportRejects(rejects, opts)
+
+	// Gather the readings into days and write them out
+	return WriteOutput(w, GroupByDayStartingAt(readings, opts.DayStart), opts)
+}
+
+// contextReader is an io.Reader that fails with the context error once
+// its context has been cancelled.
+type contextReader struct {
+	ctx context.Context // The context that may cancel reading
+	r   io.Reader       // The reader that supplies the data
+}
+
+// Read reads from the underlying reader unless the context has been cancelled.
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
@@ -0,0 +1,58 @@
+package dlycsv
+
+// Unit tests for the io.Reader / io.Writer based dlycsv API.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestConvertInMemory converts the happy path input without touching the output filesystem.
+func TestConvertInMemory(t *testing.T) {
+
+	// Load the input and expected output
+	input, err := ioutil.ReadFile("../testdata/happypath.in.csv")
+	require.Nil(t, err, "could not read input file: %v", err)
+	expected, err := ioutil.ReadFile("../testdata/happypath.expected.csv")
+	require.Nil(t, err, "could not read expected file: %v", err)
+
+	// Convert from one buffer to another
+	var output bytes.Buffer
+	err = Convert(context.Background(), bytes.NewReader(input), &output, Options{})
+	require.Nil(t, err, "Convert returned an error: %v", err)
+	require.Equal(t, string(expected), output.String(), "output did not match expected")
+}
+
+// TestConvertBadHeaderWritesNothing confirms that nothing is written if the input is not
+// blood pressure CSV data.
+func TestConvertBadHeaderWritesNothing(t *testing.T) {
+
+	var output bytes.Buffer
+	err := Convert(context.Background(), strings.NewReader("Not,A,Blood,Pressure,File\n"), &output, Options{})
+	require.NotNil(t, err, "expected error because input has a bad header")
+	require.Equal(t, FormatError, ClassOf(err), "bad header should be a format error")
+	require.Zero(t, output.Len(), "nothing should have been written")
+}
+
+// TestConvertCancelled confirms that a cancelled context stops the conversion.
+func TestConvertCancelled(t *testing.T) {
+
+	// Cancel the context before we even start
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The conversion should report the cancellation
+	var output bytes.Buffer
+	err := Convert(ctx, strings.NewReader("Date Time,Systolic,Diastolic,Pulse,Note\n"), &output, Options{})
+	require.Equal(t, context.Canceled, err, "expected the context error")
+	require.Zero(t, output.Len(), "nothing should have been written")
+}
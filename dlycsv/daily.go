@@ -3,10 +3,45 @@
 // potentially many readings concatenated onto that one line.
 //
 // The input file format is expected to be that exported from Omron blod pressure
-// tracking smart phone application.
+// tracking smart phone application, either the original five column format or one of
+// the newer Omron Connect formats with their extra indicator columns. Other input
+// formats, such as those of Withings, iHealth and Qardio, and the XML export of Apple
+// Health, are supported through the InputFormat registry: the format of an input is
+// detected from its content unless the Format of the Options selects one. RegisterFormat
+// adds formats of your own, and NewGenericFormat describes a one-off CSV layout by its
+// column names.
+//
+// Readings that are not physiologically plausible, according to the Limits of the Options,
+// are rejected, flagged or fixed as the Implausible action of the Options says. Rejected
+// records, and implausible readings, are passed to the Rejected handler of the Options and
+// can be listed with WriteRejects. Times without an offset of their own are taken to be in
+// the InputZone of the Options, and readings are gathered into days in the DisplayZone, if
+// one is given, as parsed by ParseZone. Days start at midnight unless the DayStart of the
+// Options, as parsed by ParseDayStart, gives a later time. Readings taken outside the From
+// and To days of the Options are left out altogether; LastPeriod works out the From day of
+// a recent period.
 //
 // The output is sorted in ascending date order, ready to be imported into Excel,
-// Numbers, or Google Sheets for plotting in a chart.
+// Numbers, or Google Sheets for plotting in a chart. It is written as CSV data unless the
+// Output of the Options selects XLSXOutput, for an Excel workbook, SVGOutput, for a
+// ready made chart, or HTMLOutput, for a self-contained report. If the Options ask for
+// Classify, readings and days are given the blood pressure Category of their Guideline,
+// AHA2017 unless ESH2023 is chosen.
+//
+// ConvertBloodPressureCSVToDaily works with file paths while Convert works with any
+// io.Reader and io.Writer, for callers that hold their data in memory. ConvertFiles merges
+// the readings of several inputs, such as overlapping exports, leaving out repeats.
+// UpdateDailyFile merges new readings into a daily file written earlier, read back with
+// ReadDaily.
+//
+// Each step of the conversion is also available separately: ParseReadings converts CSV
+// data into typed Reading values, GroupByDay, or GroupByDayStartingAt, gathers those
+// readings into a DailyGroup for each day, and WriteDaily or WriteXLSX render the groups
+// as daily CSV data or a workbook. For long histories, GroupByPeriod gathers the days into
+// weeks or months and WritePeriods writes one line of statistics per period, as WriteOutput
+// does when the Options GroupBy a Period longer than a day. The Rolling windows of the
+// Options add rolling averages, with their least-squares Trend, to either. EvaluateProtocol
+// measures readings against a home monitoring Protocol, such as ESHHomeProtocol.
 //
 // Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
 //
@@ -14,42 +49,104 @@
 package dlycsv
 
 import (
-	"bufio"
+	"context"
 	"encoding/csv"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
-	"sort"
-	"strconv"
-	"time"
 )
 
-// All records that are to be thrown away later will be tagged with a ZZZZ value in their first field
-const discardMarker = "ZZZZ"
+// StdioPath is the file path that selects standard input when given as an input path,
+// or standard output when given as an output path.
+const StdioPath = "-"
 
 // ConvertBloodPressureCSVToDaily reads the blood pressure CSV file at the input path, sorts the
 // data, then gathers lines that are for the same day into a single line, sending the results to
 // a new CSV file at the output path. If the output file alraedy exists, it will only be
 // overwritten if the overwrite flag is true.
+//
+// Either path may be given as StdioPath ("-") to read from standard input or write to
+// standard output respectively.
 func ConvertBloodPressureCSVToDaily(inputPath, outputPath string, overwrite bool) error {
+	return ConvertFile(inputPath, outputPath, overwrite, Options{})
+}
+
+// ConvertFile does the same as ConvertBloodPressureCSVToDaily with the conversion controlled
+// by the given options. If the options do not name a source, the input path is used, and if
+// they leave the output format to AutoOutput, it is chosen from the output path extension.
+func ConvertFile(inputPath, outputPath string, overwrite bool, opts Options) error {
+	return ConvertFiles([]string{inputPath}, outputPath, overwrite, opts)
+}
+
+// ConvertFiles does the same as ConvertFile for any number of inputs, merging the readings
+// of every input together with MergeReadings, if there is more than one, before they are
+// gathered into days. The input paths are expanded by ExpandInputPaths, so each may be a
+// wildcard pattern or a directory. Readings left out because they conflict with those of
+// an earlier input are passed to the Rejected handler of the options.
+func ConvertFiles(inputPaths []string, outputPath string, overwrite bool, opts Options) error {
 
 	// If we cannot write to the output file for any knowable reason
 	// then we should not waste any time processing the input data
-	if err := canWeWriteToFile(outputPath, overwrite); err != nil {
-		return fmt.Errorf("output file already exists: %w", err)
+	if outputPath != StdioPath {
+		if err := canWeWriteToFile(outputPath, overwrite); err != nil {
+			return classifiedErrorf(OutputError, "output file already exists: %w", err)
+		}
+	}
+
+	// Read and merge the readings of every input file
+	readings, rejects, _, err := parseFiles(context.Background(), inputPaths, opts)
+	if err != nil {
+		return err
+	}
+	reportRejects(rejects, opts)
+	groups := GroupByDayStartingAt(readings, opts.DayStart)
+
+	// Choose the output format from the output path if the options leave it to us
+	if opts.Output == AutoOutput {
+		opts.Output = OutputFormatFor(outputPath)
+	}
+
+	// Standard output needs no opening (or closing)
+	if outputPath == StdioPath {
+		return WriteOutput(os.Stdout, groups, opts)
+	}
+
+	// Prepare the output file, to be opened when there is something to write to it
+	outputFile := &deferredOutputFile{path: outputPath}
+
+	// Write the days out, making sure that the output file gets closed whatever happens
+	err = WriteOutput(outputFile, groups, opts)
+	if closeErr := outputFile.Close(); err == nil && closeErr != nil {
+		err = classifiedErrorf(OutputError, "failed to close output file: %w", closeErr)
+	}
+	return err
+}
+
+// openInputFile opens the file at the given path for reading, or returns standard input
+// if the path is StdioPath. Closing standard input is left to the operating system.
+func openInputFile(inputPath string) (io.ReadCloser, error) {
+
+	// Standard input is not ours to close
+	if inputPath == StdioPath {
+		return ioutil.NopCloser(os.Stdin), nil
 	}
 
 	// Open the input file
 	inputFile, err := os.Open(inputPath)
 	if err != nil {
-		return fmt.Errorf("could not open input file: %w", err)
+		return nil, classifiedErrorf(InputError, "could not open input file: %w", err)
 	}
-	defer inputFile.Close()
-
-	// Obtain a buffered CSV reader on the input file
-	reader := csv.NewReader(bufio.NewReader(inputFile))
+	return inputFile, nil
+}
 
-	// Handoff to our siblig to do the rest
-	return checkForHeaderRecord(reader, outputPath)
+// sourceName returns the name to be recorded as the source of readings loaded from
+// the given input path.
+func sourceName(inputPath string) string {
+	if inputPath == StdioPath {
+		return "stdin"
+	}
+	return inputPath
 }
 
 // canWeWriteToFile determines, the the best of our ability at this point, whether
@@ -69,7 +166,7 @@ func canWeWriteToFile(filePath string, overwrite bool) error {
 
 		// If we are not allowed overwrite an existing file - we can't write to this file
 		if !overwrite {
-			return fmt.Errorf("cannot overwrite existing file (%s): %w", filePath, err)
+			return fmt.Errorf("cannot overwrite existing file: %s", filePath)
 		}
 
 	} else if !os.IsNotExist(err) {
@@ -85,259 +182,85 @@ func canWeWriteToFile(filePath string, overwrite bool) error {
 	return nil
 }
 
-// checkForHeaderRecord checks that the first input record is a valid blood presssure
-// column name header record and then hands off to the next step in the flow.
-func checkForHeaderRecord(reader *csv.Reader, outputPath string) error {
-
-	// Read the first line of the input CSV file - it should be column titles
-	headerRecord, err := reader.Read()
-	if err != nil {
-		return fmt.Errorf("failed to read blood pressure CSV header record: %w", err)
-	}
+// deferredOutputFile is an io.Writer that does not create or truncate its file until the
+// first write, so that an existing file is left alone if the input proves to be unusable.
+type deferredOutputFile struct {
+	path string   // The path of the output file
+	file *os.File // The output file, once it has been opened
+}
 
-	// Confirm that the header record contains the expected values for a blood pressure history
-	if len(headerRecord) != 5 ||
-		headerRecord[0] != "Date Time" ||
-		headerRecord[1] != "Systolic" ||
-		headerRecord[2] != "Diastolic" ||
-		headerRecord[3] != "Pulse" ||
-		headerRecord[4] != "Note" {
-		return fmt.Errorf("header record of input file does not match blood pressure CSV format")
+// Write opens the output file, if it has not been opened already, recreating/emptying it if
+// it already exists, and then writes the given bytes to it.
+func (d *deferredOutputFile) Write(p []byte) (int, error) {
+	if d.file == nil {
+		file, err := os.OpenFile(d.path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
+		if err != nil {
+			return 0, fmt.Errorf("failed to open output file: %w", err)
+		}
+		d.file = file
 	}
-
-	// Now that we have confirmed that we have a blood pressure CSV file we can
-	// go on to the next phase
-	return openOutputFile(reader, outputPath)
+	return d.file.Write(p)
 }
 
-// openOutputFile opens the output file, truncating any existing content
-// then hands off to the next step in the flow.
-func openOutputFile(reader *csv.Reader, outputPath string) error {
-
-	// Open the output file, recreating/emptying it if it already exists
-	outputFile, err := os.OpenFile(outputPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
-	if err != nil {
-		return fmt.Errorf("failed to open output file: %w", err)
+// Close closes the output file if it was ever opened.
+func (d *deferredOutputFile) Close() error {
+	if d.file == nil {
+		return nil
 	}
-
-	// We can safely close the file on exit since the CSV writer used further down
-	// the stack flushes output
-	defer outputFile.Close()
-	writer := csv.NewWriter(outputFile)
-
-	// Have our deeper sibling do the remainder of the reading and writing
-	return sortInput(reader, writer)
+	return d.file.Close()
 }
 
-// sortInput loads the rest of the input file, sorts those records into ascending order,
-// then hands off to the next step in the flow.
-func sortInput(reader *csv.Reader, writer *csv.Writer) error {
+// checkForHeaderRecord checks that the first input record is a valid blood presssure
+// column name header record for the given format, or any registered format if the format
+// is nil, returning the parser for the records that follow.
+func checkForHeaderRecord(reader *recordReader, format InputFormat) (RecordParser, error) {
 
-	// Load the input CSV data (excluding the already processed inputHeader)
-	records, err := reader.ReadAll()
+	// Read the first line of the input CSV file - it should be column titles
+	headerRecord, err := reader.Read()
 	if err != nil {
-		return fmt.Errorf("failed to read body of input file: %w", err)
+		return nil, classifiedErrorf(FormatError, "failed to read blood pressure CSV header record: %w", err)
 	}
 
-	// Convert the date time value in each record into a sortable format
-	convertBPDateTimes(&records)
+	// Confirm that the header record contains the expected values for a blood pressure history
+	return detectFormat(format, headerRecord.Fields)
+}
 
-	// Sort the records into descending order
-	sort.Slice(records, func(i, j int) bool { return records[i][0] < records[j][0] })
+// WriteDaily writes the given daily groups to w as CSV data, one line per day, preceded by
+// a header record. By default the header has enough numbered sets of column names for the
+// day with the most readings. If the options define time slots, each slot has its own
+// named sets of columns instead.
+//
+// If the options select a policy other than KeepAll, the policy is applied to the readings
+// of each slot, or of each day if there are no slots, and named in the header record.
+//
+// Fields that only some input formats provide, such as irregular heartbeat detection, are
+// given columns of their own if any of the readings has a value for them.
+//
+// If the options ask to FillGaps, a line is written for every day of the calendar, with
+// the date of each day in a leading column so that days without readings still have one.
+func WriteDaily(w io.Writer, groups []DailyGroup, opts Options) error {
 
-	// Combine records for the same date into single records
-	maxReadingsInOneDay := combineRecordsForSameDay(&records)
+	// Decide on how each day is to be laid out
+	groups = fillGaps(groups, opts)
+	layout := newDailyLayout(groups, opts)
 
-	// Write a header record, repeating the column names to match the most readings for a single day
-	header := buildHeaderRecord(maxReadingsInOneDay)
-	err = writer.Write(header)
+	// Write the header record
+	writer := csv.NewWriter(w)
+	err := writer.Write(layout.buildHeaderRecord())
 	if err != nil {
-		return fmt.Errorf("failed to write header to output file: %w", err)
+		return classifiedErrorf(OutputError, "failed to write header to output file: %w", err)
 	}
 
-	// Eliminate all the records records marked for discard
-	discardMarkedRecords(&records)
-
-	// Make sure we flush the writer when we are done
-	defer writer.Flush()
-
-	// Write the body of the data
+	// Write the body of the data, one record per day
+	records := make([][]string, 0, len(groups))
+	for _, group := range groups {
+		records = append(records, layout.buildDailyRecord(group))
+	}
 	err = writer.WriteAll(records)
 	if err != nil {
-		return fmt.Errorf("failed to write blood pressure data to output file: %w", err)
+		return classifiedErrorf(OutputError, "failed to write blood pressure data to output file: %w", err)
 	}
 
 	// Glorious - we are completely finished
 	return nil
 }
-
-// combineRecordsForSameDay merges consecutive records for the same day onto the end of
-// the first record for that date, marking the following records as discardable.
-//
-// Returns the maxium number of readings accumulated into a single day.
-func combineRecordsForSameDay(records *[][]string) int {
-
-	// Assume that we will accumulate the second record into the first
-	accumulateIndex := 0
-
-	// When we find the first date string, this is where we will track
-	// the date we are accumulating for
-	var accumulateDate string
-
-	// How many readinsg have we accumulated in the current target so far
-	readingsAccumulatedSoFar := 1
-
-	// We also track the maxium number of readings found for the same day
-	// so that we can put out a header record with column names to match
-	maxReadingsInOneDay := 1
-
-	// Loop through all of the records
-	for index, record := range *records {
-
-		// If we have reached a discardable record, we can stop looping.
-		// Every record beyond this one will also be discardable
-		if record[0] == discardMarker {
-			break
-		}
-
-		// Collect the date portion of the first field
-		recordDate := record[0][0:10]
-
-		// Special case - the first record has to be stepped over as there
-		// is no prior record to accumulate into. We will look to accumulate
-		// into this one.
-		if index == 0 {
-			accumulateDate = recordDate
-			continue
-		}
-
-		// The normal case, does the date of this record match the one we are
-		// accumulating into?
-		if recordDate == accumulateDate {
-
-			// We have a match - append this record's fields to the accumulate record
-			(*records)[accumulateIndex] = append((*records)[accumulateIndex], record...)
-
-			// Keep track of the number of accumulations into the target record so far
-			readingsAccumulatedSoFar++
-
-			// Mark the current record for discard
-			record[0] = discardMarker
-
-			// Move on to the next record
-			continue
-		}
-
-		// The current record does not match the date of the previous accumulation record
-		// First lets see if we have a new high point for the number of readings made on a single day
-		if readingsAccumulatedSoFar > maxReadingsInOneDay {
-			maxReadingsInOneDay = readingsAccumulatedSoFar
-		}
-
-		// Tag the new guy as the accumulator from now on
-		accumulateIndex = index
-		accumulateDate = recordDate
-		readingsAccumulatedSoFar = 1
-	}
-
-	// Return the maxium number of accumulations into a single day
-	return maxReadingsInOneDay
-}
-
-// convertBPDateTimes converts the date-time values in the first field of each of
-// the given blood pressure records to a sortable YYYY-MM-DD hh:mm:ss form.
-//
-// If any record is found not to contain a date value, its first field will be set to "ZZZZ"
-// so that it can later be sorted to the end of the set and easily removed
-func convertBPDateTimes(records *[][]string) {
-
-	// Loop through all of the records
-	for index, record := range *records {
-
-		// Check we have a non-zero length record!
-		if len(record) != 0 {
-
-			// Convert the date time string in the first field to a time value
-			datetime, err := time.Parse("Jan 02 2006 15:04:05", record[0])
-
-			// If the first field was a valid date time, put it back in YYYY-MM-DD hh:mm:ss form
-			if err == nil {
-
-				// Convert the time value to our desired form and stuff it back in the record
-				(*records)[index][0] = datetime.Format("2006-01-02 15:04:05")
-
-			} else {
-
-				// Darn - this reord is duff
-				(*records)[index][0] = discardMarker
-			}
-
-		} else {
-
-			// NOTE: This is a safety net, just in case. In practice the
-			// encoding/csv package discards blank lines so we should never
-			// get here.
-
-			// We have an empty record, add the discard marker
-			(*records)[index] = append((*records)[index], discardMarker)
-		}
-	}
-}
-
-// discardMarkedRecords eliminates all records records marked for discard.
-func discardMarkedRecords(records *[][]string) {
-
-	// Sort the records into descending order
-	sort.Slice(*records, func(i, j int) bool { return (*records)[i][0] < (*records)[j][0] })
-
-	// Start at the bottom and work back up to find the first legitimate record
-	index := len(*records) - 1
-	for ; index > 0; index-- {
-		if (*records)[index][0] != discardMarker {
-			break
-		}
-	}
-
-	// Index is now the last good record, we discard the rest
-	*records = (*records)[:index+1]
-}
-
-// buildHeaderRecord assembles one or more sets of blood pressure CSV file column headers
-// into a string array record.
-func buildHeaderRecord(maxReadingsInOneDay int) []string {
-
-	// Build our header record here
-	var header []string
-
-	// Loop for the max reading count adding numbered header sections
-	for i := 0; i < maxReadingsInOneDay; {
-
-		// We want to start our numbering at 1 so increment the loop index inside the loop
-		i++
-
-		// Add a numbered set of column headings
-		addHeadingSet(&header, i)
-	}
-
-	// And we have our finished header
-	return header
-}
-
-// addHeadingSet appends one set of column names to the header record
-func addHeadingSet(header *[]string, setNumber int) {
-
-	// Convert the set number to text
-	setNumberText := strconv.Itoa(setNumber)
-
-	// Build an array of numbered heading names
-	headingSet := []string{
-		"Date Time " + setNumberText,
-		"Systolic " + setNumberText,
-		"Diastolic " + setNumberText,
-		"Pulse " + setNumberText,
-		"Note " + setNumberText,
-	}
-
-	// Add the set to the header
-	*header = append(*header, headingSet...)
-}
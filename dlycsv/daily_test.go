@@ -151,23 +151,19 @@ func TestBadBody(t *testing.T) {
 	require.Contains(t, err.Error(), "failed to read body of input file")
 }
 
-// TestConversionOfEmptyRecords exercises the low level convertBPDateTimes(..)
-// function to confirm that it would correctly handle empty records if the
-// encoding/csv package ever changed its practice and failed to strip them
-// when the file is read.
-func TestConversionOfEmptyRecords(t *testing.T) {
-
-	// Pass in two empty records and confirm that they get a discard marker
-	// field added to them
-	var records = make([][]string, 2, 2)
-	convertBPDateTimes(&records)
-
-	// We should have two entries now with the discard marker in their first (and only) field
-	require.Equal(t, len(records), 2, "there should still be only two records")
-	require.Equal(t, len(records[0]), 1, "first record should have one field")
-	require.Equal(t, records[0][0], discardMarker, "first record should have a discard marker")
-	require.Equal(t, len(records[1]), 1, "second record should have one field")
-	require.Equal(t, records[1][0], discardMarker, "second record should have a discard marker")
+// TestParsingOfEmptyRecords exercises the low level Omron record parsing to
+// confirm that it would correctly reject empty records if they ever reached it.
+func TestParsingOfEmptyRecords(t *testing.T) {
+
+	// Detect the columns of the original Omron format
+	parse, ok := OmronFormat.Detect([]string{"Date Time", "Systolic", "Diastolic", "Pulse", "Note"})
+	require.True(t, ok, "the original Omron header should be recognized")
+
+	// Neither a nil nor an empty record is a reading
+	_, err := parse(nil)
+	require.NotNil(t, err, "a nil record should not be a reading")
+	_, err = parse([]string{})
+	require.NotNil(t, err, "an empty record should not be a reading")
 }
 
 // buildHappyFilePaths constructs the file paths of the input, output, and expected
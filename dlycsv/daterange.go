@@ -0,0 +1,67 @@
+package dlycsv
+
+// The filtering of readings by the days on which they were taken.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// inDateRange returns true if the given time falls on a day between the From and To
+// days of the options, inclusive, either of which is open if it is the zero time. Days
+// are compared by their calendar dates, whatever the time zones of the times, and a time
+// before the DayStart of the options belongs to the day before.
+func (opts Options) inDateRange(t time.Time) bool {
+	day := dayOf(t, opts.DayStart)
+	if !opts.From.IsZero() && day.Before(dateIn(opts.From, t.Location())) {
+		return false
+	}
+	if !opts.To.IsZero() && day.After(dateIn(opts.To, t.Location())) {
+		return false
+	}
+	return true
+}
+
+// dateIn returns midnight at the start of the calendar date of the given time in the
+// given location.
+func dateIn(t time.Time, location *time.Location) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, location)
+}
+
+// LastPeriod returns the first day of a period that ends with the day of the given time
+// and lasts for the given length: a whole number followed by d for days, w for weeks, m for
+// months or y for years, such as "30d" or "6m". A period of "1d" is the given day alone.
+// The result is midnight at the start of the first day, suitable for the From option.
+func LastPeriod(length string, today time.Time) (time.Time, error) {
+
+	// Split the length into its count and unit
+	length = strings.TrimSpace(strings.ToLower(length))
+	if len(length) < 2 {
+		return time.Time{}, fmt.Errorf("period %q is not a number followed by d, w, m or y", length)
+	}
+	count, err := strconv.Atoi(length[:len(length)-1])
+	if err != nil || count < 1 {
+		return time.Time{}, fmt.Errorf("period %q is not a number followed by d, w, m or y", length)
+	}
+
+	// Step back from the day after the given day, so that the given day is included
+	end := startOfDay(today).AddDate(0, 0, 1)
+	switch length[len(length)-1] {
+	case 'd':
+		return startOfDay(end.AddDate(0, 0, -count)), nil
+	case 'w':
+		return startOfDay(end.AddDate(0, 0, -7*count)), nil
+	case 'm':
+		return startOfDay(end.AddDate(0, -count, 0)), nil
+	case 'y':
+		return startOfDay(end.AddDate(-count, 0, 0)), nil
+	}
+	return time.Time{}, fmt.Errorf("period %q is not a number followed by d, w, m or y", length)
+}
@@ -0,0 +1,79 @@
+package dlycsv
+
+// Unit tests for the filtering of readings by date.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestDateRange confirms that readings outside the From and To days are left out.
+func TestDateRange(t *testing.T) {
+	inputFile, err := os.Open("../testdata/happypath.in.csv")
+	require.Nil(t, err, "could not open input file: %v", err)
+	defer inputFile.Close()
+
+	// The days are inclusive, whatever the time of day given
+	opts := Options{
+		From: time.Date(2020, 4, 29, 23, 59, 0, 0, time.UTC),
+		To:   time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC),
+	}
+	readings, err := ParseReadings(context.Background(), inputFile, opts)
+	require.Nil(t, err, "ParseReadings returned an error: %v", err)
+	require.Len(t, readings, 7)
+	for _, reading := range readings {
+		require.True(t, reading.Time.Day() >= 29 || reading.Time.Month() == time.May, "%v is too early", reading.Time)
+		require.False(t, reading.Time.After(time.Date(2020, 5, 2, 0, 0, 0, 0, time.UTC)), "%v is too late", reading.Time)
+	}
+
+	// The days of the range are compared by date, not by instant
+	offset := time.FixedZone("PDT", -7*60*60)
+	opts = Options{From: time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)}
+	require.True(t, opts.inDateRange(time.Date(2020, 5, 1, 6, 0, 0, 0, offset)))
+	require.False(t, opts.inDateRange(time.Date(2020, 4, 30, 21, 0, 0, 0, offset)))
+}
+
+// TestDateRangeCalendar confirms that the date range is the default calendar for filling gaps.
+func TestDateRangeCalendar(t *testing.T) {
+	var output bytes.Buffer
+	opts := Options{
+		FillGaps: true,
+		From:     time.Date(2020, 4, 30, 0, 0, 0, 0, time.UTC),
+		To:       time.Date(2020, 5, 10, 0, 0, 0, 0, time.UTC),
+	}
+	require.Nil(t, WriteDaily(&output, GroupByDay(gapReadings()), opts))
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	require.Len(t, lines, 12, "a header and eleven days were expected")
+	require.Equal(t, "2020-04-30", lines[1])
+	require.Equal(t, "2020-05-10", lines[11])
+}
+
+// TestLastPeriod confirms the first days of periods that end today.
+func TestLastPeriod(t *testing.T) {
+	today := time.Date(2020, 5, 31, 15, 30, 0, 0, time.UTC)
+	for length, expected := range map[string]time.Time{
+		"1d":  time.Date(2020, 5, 31, 0, 0, 0, 0, time.UTC),
+		"30d": time.Date(2020, 5, 2, 0, 0, 0, 0, time.UTC),
+		"2W":  time.Date(2020, 5, 18, 0, 0, 0, 0, time.UTC),
+		"1m":  time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC),
+		"1y":  time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		from, err := LastPeriod(length, today)
+		require.Nil(t, err, "LastPeriod returned an error for %q: %v", length, err)
+		require.Equal(t, expected, from, "wrong first day for %q", length)
+	}
+	for _, length := range []string{"", "d", "30", "0d", "-1d", "3h", "xd"} {
+		_, err := LastPeriod(length, today)
+		require.NotNil(t, err, "%q should have been refused", length)
+	}
+}
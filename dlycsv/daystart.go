@@ -0,0 +1,38 @@
+package dlycsv
+
+// The boundary between one day and the next, which need not be midnight for those
+// who take their last reading of the day after it.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"fmt"
+	"time"
+)
+
+// ParseDayStart parses the time of day at which each day starts, in hh:mm form, returning
+// it as the duration since midnight. Readings taken before it belong to the previous day.
+func ParseDayStart(text string) (time.Duration, error) {
+	dayStart, err := parseTimeOfDay(text)
+	if err != nil {
+		return 0, err
+	}
+	if dayStart >= 12*time.Hour {
+		return 0, fmt.Errorf("%q is too late for the day to start, which must be before 12:00", text)
+	}
+	return dayStart, nil
+}
+
+// dayOf returns midnight at the start of the calendar date of the day to which the given
+// time belongs: its own date, unless its clock reading is earlier than the day start, in
+// which case it belongs to the day before. Clock readings are compared, rather than times,
+// so that the boundary stays put when daylight saving starts or ends.
+func dayOf(t time.Time, dayStart time.Duration) time.Time {
+	day := startOfDay(t)
+	if timeOfDay(t) < dayStart {
+		day = startOfDay(day.AddDate(0, 0, -1))
+	}
+	return day
+}
@@ -0,0 +1,84 @@
+package dlycsv
+
+// Unit tests for days that start after midnight.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// nightOwlReadings returns readings taken late in the evening and after midnight.
+func nightOwlReadings() []Reading {
+	return []Reading{
+		{Time: time.Date(2020, 5, 1, 7, 0, 0, 0, time.UTC), Systolic: 120, Diastolic: 80, Pulse: 60},
+		{Time: time.Date(2020, 5, 1, 23, 10, 0, 0, time.UTC), Systolic: 130, Diastolic: 84, Pulse: 62},
+		{Time: time.Date(2020, 5, 2, 0, 30, 0, 0, time.UTC), Systolic: 126, Diastolic: 82, Pulse: 64},
+		{Time: time.Date(2020, 5, 2, 4, 0, 0, 0, time.UTC), Systolic: 118, Diastolic: 78, Pulse: 58},
+	}
+}
+
+// TestParseDayStart confirms the day starts that are accepted.
+func TestParseDayStart(t *testing.T) {
+	dayStart, err := ParseDayStart("04:30")
+	require.Nil(t, err, "ParseDayStart returned an error: %v", err)
+	require.Equal(t, 4*time.Hour+30*time.Minute, dayStart)
+	dayStart, err = ParseDayStart("00:00")
+	require.Nil(t, err, "ParseDayStart returned an error: %v", err)
+	require.Equal(t, time.Duration(0), dayStart)
+	for _, text := range []string{"", "4", "04:60", "12:00", "24:00", "-1:00"} {
+		_, err := ParseDayStart(text)
+		require.NotNil(t, err, "%q should have been refused", text)
+	}
+}
+
+// TestGroupByDayStartingAt confirms that readings before the day start join the day before.
+func TestGroupByDayStartingAt(t *testing.T) {
+
+	// At midnight, the 00:30 reading starts the next day
+	groups := GroupByDayStartingAt(nightOwlReadings(), 0)
+	require.Len(t, groups, 2)
+	require.Len(t, groups[0].Readings, 2)
+
+	// At 04:00, it joins the evening before, while a reading at 04:00 starts the next day
+	groups = GroupByDayStartingAt(nightOwlReadings(), 4*time.Hour)
+	require.Len(t, groups, 2)
+	require.Equal(t, time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC), groups[0].Date)
+	require.Len(t, groups[0].Readings, 3)
+	require.Equal(t, time.Date(2020, 5, 2, 0, 0, 0, 0, time.UTC), groups[1].Date)
+	require.Len(t, groups[1].Readings, 1)
+
+	// The boundary is a clock reading, so it does not move when the clocks change
+	london, err := time.LoadLocation("Europe/London")
+	require.Nil(t, err, "could not load time zone: %v", err)
+	require.Equal(t, time.Date(2020, 3, 28, 0, 0, 0, 0, london), dayOf(time.Date(2020, 3, 29, 3, 59, 0, 0, london), 4*time.Hour))
+	require.Equal(t, time.Date(2020, 3, 29, 0, 0, 0, 0, london), dayOf(time.Date(2020, 3, 29, 4, 0, 0, 0, london), 4*time.Hour))
+}
+
+// TestDayStartOptions confirms that the day start is applied to the slots, statistics
+// and date range of the daily output.
+func TestDayStartOptions(t *testing.T) {
+	slots, err := ParseSlots("Morning=04:00-12:00,Evening=18:00-04:00")
+	require.Nil(t, err, "ParseSlots returned an error: %v", err)
+	opts := Options{Slots: slots, Policy: Average, Stats: true, DayStart: 4 * time.Hour}
+
+	// The evening slot of the first day averages the readings either side of midnight
+	var output bytes.Buffer
+	require.Nil(t, WriteDaily(&output, GroupByDayStartingAt(nightOwlReadings(), opts.DayStart), opts))
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	require.Len(t, lines, 3)
+	require.True(t, strings.HasPrefix(lines[1], "2020-05-01 07:00:00,120,80,60,,2020-05-01 23:50:00,128,83,63,,"), "unexpected first day: %s", lines[1])
+	require.Contains(t, lines[1], ",3,", "the statistics should count three readings")
+
+	// The date range counts the 00:30 reading as part of the first day
+	opts = Options{DayStart: 4 * time.Hour, To: time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)}
+	require.True(t, opts.inDateRange(time.Date(2020, 5, 2, 0, 30, 0, 0, time.UTC)))
+	require.False(t, opts.inDateRange(time.Date(2020, 5, 2, 4, 0, 0, 0, time.UTC)))
+}
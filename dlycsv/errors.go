@@ -0,0 +1,57 @@
+package dlycsv
+
+// Classified errors returned by the dlycsv package so that callers, such as the
+// bpdaily command line, can tell one class of failure from another.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorClass identifies the broad category of a failure.
+type ErrorClass int
+
+// The classes of failure that the dlycsv package can report.
+const (
+	UnknownError ErrorClass = iota // The failure could not be classified
+	InputError                     // The input could not be opened or read
+	FormatError                    // The input was read but its content was not acceptable
+	OutputError                    // The output could not be created or written
+	OptionsError                   // The options given conflict with an input whose content is fine
+)
+
+// ClassifiedError wraps an underlying error with the class of failure that it represents.
+type ClassifiedError struct {
+	Class ErrorClass // The category of the failure
+	Err   error      // The underlying error, carrying the descriptive message
+}
+
+// Error returns the message of the underlying error.
+func (e *ClassifiedError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error so that errors.Is and errors.As can see through us.
+func (e *ClassifiedError) Unwrap() error {
+	return e.Err
+}
+
+// ClassOf returns the class of the given error, or UnknownError if the
+// error was not classified by this package.
+func ClassOf(err error) ErrorClass {
+	var classified *ClassifiedError
+	if errors.As(err, &classified) {
+		return classified.Class
+	}
+	return UnknownError
+}
+
+// classifiedErrorf formats an error message in the same way as fmt.Errorf and
+// wraps the result with the given class of failure.
+func classifiedErrorf(class ErrorClass, format string, a ...interface{}) error {
+	return &ClassifiedError{Class: class, Err: fmt.Errorf(format, a...)}
+}
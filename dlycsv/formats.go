@@ -0,0 +1,146 @@
+package dlycsv
+
+// The pluggable input formats, and the registry through which they are selected
+// by name or detected from the header record of the input.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// InputFormat is a CSV file format from which blood pressure readings can be parsed,
+// such as the export format of a particular monitor vendor's app.
+type InputFormat interface {
+
+	// Name returns the name by which the format is registered and selected.
+	Name() string
+
+	// Detect examines the header record of a CSV file and, if the format recognizes it,
+	// returns the RecordParser for the records that follow the header.
+	Detect(header []string) (RecordParser, bool)
+}
+
+// RecordParser converts the fields of a CSV record into a Reading, returning an error if
+// the record is not a valid reading. The Source and Line of the reading are filled in by
+// the caller.
+type RecordParser func(fields []string) (Reading, error)
+
+// DocumentFormat is implemented by input formats, such as the Apple Health export, whose
+// inputs are not CSV files with a header record. Such a format is detected by sniffing the
+// start of the input, and reads the whole of the input itself.
+type DocumentFormat interface {
+	InputFormat
+
+	// Sniff returns true if the given start of an input looks like one of the format's documents.
+	Sniff(prefix []byte) bool
+
+	// ParseDocument reads the readings from the whole of an input, returning them in the
+	// order that they were found together with the items that were rejected because they
+	// were not valid readings. The Source of each reading and reject is filled in by the caller.
+	ParseDocument(ctx context.Context, r io.Reader) ([]Reading, []Reject, error)
+}
+
+// The registered input formats, in the order that they are tried when detecting the
+// format of an input.
+var (
+	formatsMutex sync.RWMutex
+	formats      []InputFormat
+)
+
+// Register the formats that we provide ourselves
+func init() {
+	RegisterFormat(OmronFormat)
+	RegisterFormat(WithingsFormat)
+	RegisterFormat(IHealthFormat)
+	RegisterFormat(QardioFormat)
+	RegisterFormat(AppleHealthFormat)
+}
+
+// RegisterFormat makes an input format available for selection by name and for detection.
+// Formats are tried in the order that they were registered when detecting the format of an
+// input. RegisterFormat panics if the format is nil or its name is already registered.
+func RegisterFormat(format InputFormat) {
+	formatsMutex.Lock()
+	defer formatsMutex.Unlock()
+	if format == nil {
+		panic("dlycsv: RegisterFormat format is nil")
+	}
+	for _, registered := range formats {
+		if strings.EqualFold(registered.Name(), format.Name()) {
+			panic("dlycsv: RegisterFormat called twice for format " + format.Name())
+		}
+	}
+	formats = append(formats, format)
+}
+
+// LookupFormat returns the registered input format with the given name, ignoring case.
+func LookupFormat(name string) (InputFormat, error) {
+	formatsMutex.RLock()
+	defer formatsMutex.RUnlock()
+	var names []string
+	for _, format := range formats {
+		if strings.EqualFold(format.Name(), name) {
+			return format, nil
+		}
+		names = append(names, format.Name())
+	}
+	return nil, fmt.Errorf("unknown input format %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+// Formats returns the registered input formats, in the order that they are tried when
+// detecting the format of an input.
+func Formats() []InputFormat {
+	formatsMutex.RLock()
+	defer formatsMutex.RUnlock()
+	return append([]InputFormat(nil), formats...)
+}
+
+// detectDocumentFormat returns the DocumentFormat that should read an input that starts
+// with the given prefix: the given format if it is a DocumentFormat or, if no format is
+// given, the first registered DocumentFormat that recognizes the prefix. Returns nil if
+// the input should be read as CSV.
+func detectDocumentFormat(format InputFormat, prefix []byte) DocumentFormat {
+
+	// If we have been told the format, we do as we are told
+	if format != nil {
+		document, _ := format.(DocumentFormat)
+		return document
+	}
+
+	// Otherwise we sniff with each of the registered document formats
+	for _, candidate := range Formats() {
+		if document, ok := candidate.(DocumentFormat); ok && document.Sniff(prefix) {
+			return document
+		}
+	}
+	return nil
+}
+
+// detectFormat returns the RecordParser of the given format for the header record or, if
+// no format is given, of the first registered format that recognizes the header record.
+func detectFormat(format InputFormat, header []string) (RecordParser, error) {
+
+	// If we have been told the format, it must recognize the header
+	if format != nil {
+		if parser, ok := format.Detect(header); ok {
+			return parser, nil
+		}
+		return nil, classifiedErrorf(FormatError,
+			"header record of input file does not match the %s blood pressure CSV format", format.Name())
+	}
+
+	// Otherwise we try each of the registered formats
+	for _, candidate := range Formats() {
+		if parser, ok := candidate.Detect(header); ok {
+			return parser, nil
+		}
+	}
+	return nil, classifiedErrorf(FormatError, "header record of input file does not match blood pressure CSV format")
+}
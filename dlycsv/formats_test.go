@@ -0,0 +1,52 @@
+package dlycsv
+
+// Unit tests for the input format registry and format detection.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestLookupFormat confirms that the built in formats can be found by name.
+func TestLookupFormat(t *testing.T) {
+
+	// Every built in format can be found, regardless of case
+	for _, name := range []string{"omron", "Withings", "IHEALTH", "qardio"} {
+		format, err := LookupFormat(name)
+		require.Nil(t, err, "could not find format %q: %v", name, err)
+		require.True(t, strings.EqualFold(name, format.Name()), "found the wrong format for %q", name)
+	}
+
+	// Unknown names are refused with a list of those that are known
+	_, err := LookupFormat("nonesuch")
+	require.NotNil(t, err, "expected error for an unknown format")
+	require.Contains(t, err.Error(), "omron, withings, ihealth, qardio")
+}
+
+// TestRegisterFormatTwice confirms that a format name cannot be registered twice.
+func TestRegisterFormatTwice(t *testing.T) {
+	require.Panics(t, func() { RegisterFormat(OmronFormat) }, "expected a panic for a duplicate format")
+	require.Panics(t, func() { RegisterFormat(nil) }, "expected a panic for a nil format")
+}
+
+// TestExplicitFormatMismatch confirms that an explicitly selected format must recognize
+// the header record, even if some other format would.
+func TestExplicitFormatMismatch(t *testing.T) {
+	input := "Date Time,Systolic,Diastolic,Pulse,Note\n2020-04-26 06:16:43,120,80,60,\n"
+	_, err := ParseReadings(context.Background(), strings.NewReader(input), Options{Format: WithingsFormat})
+	require.NotNil(t, err, "expected error because the header is not a Withings header")
+	require.Equal(t, FormatError, ClassOf(err), "mismatched header should be a format error")
+	require.Contains(t, err.Error(), "withings")
+
+	// The same input is fine with detection
+	readings, err := ParseReadings(context.Background(), strings.NewReader(input), Options{})
+	require.Nil(t, err, "ParseReadings returned an error: %v", err)
+	require.Len(t, readings, 1, "expected one reading")
+}
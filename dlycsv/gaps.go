@@ -0,0 +1,114 @@
+package dlycsv
+
+// The days on which no readings were taken: placeholder days that keep the daily output
+// a continuous calendar, and the streaks of missed days that they make up.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"time"
+)
+
+// Gap is a streak of consecutive days on which no readings were taken.
+type Gap struct {
+	From time.Time // Midnight at the start of the first missed day
+	To   time.Time // Midnight at the start of the last missed day
+	Days int       // The number of missed days
+}
+
+// FillGaps returns the given daily groups, which must be in ascending date order, with an
+// empty group added for every day without readings, so that there is a group for every day
+// from the first day to the last. The first and last days are those of the groups, unless
+// from or to are given as a time other than the zero time, in which case they are the days
+// of those times; groups outside the range are kept as they are.
+func FillGaps(groups []DailyGroup, from, to time.Time) []DailyGroup {
+	first, last, ok := calendarRange(groups, from, to)
+	if !ok {
+		return groups
+	}
+
+	// Walk the calendar, taking each group as its day comes along
+	var filled []DailyGroup
+	next := 0
+	for ; next < len(groups) && daysBetween(groups[next].Date, first) > 0; next++ {
+		filled = append(filled, groups[next])
+	}
+	for day := first; !day.After(last); day = nextDay(day) {
+		if next < len(groups) && daysBetween(groups[next].Date, day) == 0 {
+			filled = append(filled, groups[next])
+			next++
+		} else {
+			filled = append(filled, DailyGroup{Date: day})
+		}
+	}
+	return append(filled, groups[next:]...)
+}
+
+// FindGaps returns the streaks of days without readings among the given daily groups, which
+// must be in ascending date order, between the first and last days chosen as they are by
+// FillGaps.
+func FindGaps(groups []DailyGroup, from, to time.Time) []Gap {
+	var gaps []Gap
+	for _, group := range FillGaps(groups, from, to) {
+		if len(group.Readings) > 0 {
+			continue
+		}
+		if count := len(gaps); count > 0 && daysBetween(gaps[count-1].To, group.Date) == 1 {
+			gaps[count-1].To = group.Date
+			gaps[count-1].Days++
+		} else {
+			gaps = append(gaps, Gap{From: group.Date, To: group.Date, Days: 1})
+		}
+	}
+	return gaps
+}
+
+// fillGaps applies FillGaps to the groups if the options ask for gaps to be filled.
+func fillGaps(groups []DailyGroup, opts Options) []DailyGroup {
+	if !opts.FillGaps {
+		return groups
+	}
+	from, to := opts.calendar()
+	return FillGaps(groups, from, to)
+}
+
+// calendar returns the first and last days of the calendar of the options: the days given
+// for filling gaps or, if there are none, the From and To days of the date range.
+func (opts Options) calendar() (time.Time, time.Time) {
+	from, to := opts.FillFrom, opts.FillTo
+	if from.IsZero() {
+		from = opts.From
+	}
+	if to.IsZero() {
+		to = opts.To
+	}
+	return from, to
+}
+
+// calendarRange returns the first and last days of the calendar described by FillGaps,
+// and false if there is no such calendar because there are neither groups nor dates. The
+// days are in the time zone of the first group, if there is one, since the dates given
+// are calendar dates rather than instants.
+func calendarRange(groups []DailyGroup, from, to time.Time) (time.Time, time.Time, bool) {
+	var first, last time.Time
+	location := from.Location()
+	if len(groups) > 0 {
+		location = groups[0].Date.Location()
+		first, last = groups[0].Date, dateIn(groups[len(groups)-1].Date, location)
+	}
+	if !from.IsZero() {
+		first = dateIn(from, location)
+	}
+	if !to.IsZero() {
+		last = dateIn(to, location)
+	}
+	return first, last, !first.IsZero() && !last.IsZero()
+}
+
+// nextDay returns midnight at the start of the day after the given day, which need not
+// be 24 hours later if the clocks change.
+func nextDay(day time.Time) time.Time {
+	return startOfDay(day.AddDate(0, 0, 1))
+}
@@ -0,0 +1,96 @@
+package dlycsv
+
+// Unit tests for the filling and finding of days without readings.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// gapReadings returns readings taken on the 1st, 2nd, 5th and 9th of May 2020.
+func gapReadings() []Reading {
+	var readings []Reading
+	for _, day := range []int{1, 2, 5, 9} {
+		readings = append(readings, Reading{Time: time.Date(2020, 5, day, 7, 0, 0, 0, time.UTC), Systolic: 120, Diastolic: 80, Pulse: 60})
+	}
+	return readings
+}
+
+// TestFillGaps confirms that an empty group is added for each day without readings.
+func TestFillGaps(t *testing.T) {
+	groups := FillGaps(GroupByDay(gapReadings()), time.Time{}, time.Time{})
+	require.Len(t, groups, 9, "every day from the 1st to the 9th should have a group")
+	for index, group := range groups {
+		require.Equal(t, index+1, group.Date.Day())
+	}
+	require.Empty(t, groups[2].Readings)
+	require.Len(t, groups[4].Readings, 1)
+
+	// A wider calendar adds empty days at either end
+	from := time.Date(2020, 4, 29, 12, 0, 0, 0, time.UTC)
+	to := time.Date(2020, 5, 10, 0, 0, 0, 0, time.UTC)
+	groups = FillGaps(GroupByDay(gapReadings()), from, to)
+	require.Len(t, groups, 12)
+	require.Equal(t, time.Date(2020, 4, 29, 0, 0, 0, 0, time.UTC), groups[0].Date)
+
+	// A narrower calendar keeps the days outside it
+	groups = FillGaps(GroupByDay(gapReadings()), time.Date(2020, 5, 3, 0, 0, 0, 0, time.UTC), to)
+	require.Len(t, groups, 10, "the 1st and 2nd, then the 3rd to the 10th")
+
+	// Nothing can be filled without a calendar
+	require.Empty(t, FillGaps(nil, time.Time{}, time.Time{}))
+	require.Len(t, FillGaps(nil, from, to), 12)
+}
+
+// TestFillGapsAcrossDaylightSaving confirms that each day appears once when the clocks change.
+func TestFillGapsAcrossDaylightSaving(t *testing.T) {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone data is not available")
+	}
+	readings := []Reading{
+		{Time: time.Date(2020, 3, 7, 7, 0, 0, 0, location), Systolic: 120, Diastolic: 80},
+		{Time: time.Date(2020, 3, 10, 7, 0, 0, 0, location), Systolic: 120, Diastolic: 80},
+	}
+	groups := FillGaps(GroupByDay(readings), time.Time{}, time.Time{})
+	require.Len(t, groups, 4)
+	require.Equal(t, 8, groups[1].Date.Day())
+	require.Equal(t, 9, groups[2].Date.Day())
+}
+
+// TestFindGaps confirms that the streaks of missed days are found.
+func TestFindGaps(t *testing.T) {
+	gaps := FindGaps(GroupByDay(gapReadings()), time.Time{}, time.Date(2020, 5, 10, 0, 0, 0, 0, time.UTC))
+	require.Equal(t, []Gap{
+		{From: time.Date(2020, 5, 3, 0, 0, 0, 0, time.UTC), To: time.Date(2020, 5, 4, 0, 0, 0, 0, time.UTC), Days: 2},
+		{From: time.Date(2020, 5, 6, 0, 0, 0, 0, time.UTC), To: time.Date(2020, 5, 8, 0, 0, 0, 0, time.UTC), Days: 3},
+		{From: time.Date(2020, 5, 10, 0, 0, 0, 0, time.UTC), To: time.Date(2020, 5, 10, 0, 0, 0, 0, time.UTC), Days: 1},
+	}, gaps)
+}
+
+// TestFillGapsOutput confirms that the daily output has a date column and a line for
+// every day, and that it can still be read back.
+func TestFillGapsOutput(t *testing.T) {
+	var output bytes.Buffer
+	opts := Options{FillGaps: true, Stats: true}
+	require.Nil(t, WriteDaily(&output, GroupByDay(gapReadings()), opts))
+	lines := strings.Split(output.String(), "\n")
+	require.True(t, strings.HasPrefix(lines[0], "Date,Date Time 1,Systolic 1,"))
+	require.Equal(t, "2020-05-01,2020-05-01 07:00:00,120,80,60,,120,120,120,80,80,80,60,1,40,93.3", lines[1])
+	require.Equal(t, "2020-05-03,,,,,,,,,,,,,0,,", lines[3], "the empty day should have a date and no readings")
+
+	// The file can be read back, date column and all
+	readings, header, err := readDaily(context.Background(), bytes.NewReader(output.Bytes()), Options{})
+	require.Nil(t, err, "readDaily returned an error: %v", err)
+	require.True(t, header.dates)
+	require.Len(t, readings, 4)
+}
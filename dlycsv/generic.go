@@ -0,0 +1,118 @@
+package dlycsv
+
+// A generic input format for CSV files that no registered format recognizes,
+// described by the user in terms of which columns hold which fields.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The keys of a generic format description, mapped to the fields that they name columns for.
+var genericFieldKeys = map[string]int{
+	"datetime":  fieldDateTime,
+	"date":      fieldDate,
+	"time":      fieldTime,
+	"systolic":  fieldSystolic,
+	"diastolic": fieldDiastolic,
+	"pulse":     fieldPulse,
+	"note":      fieldNote,
+	"ihb":       fieldIrregularHeartbeat,
+	"movement":  fieldBodyMovement,
+	"position":  fieldPosition,
+	"zone":      fieldZone,
+}
+
+// NewGenericFormat returns an InputFormat for CSV files whose columns are described by
+// the given specification: a comma separated list of key=value pairs naming the column
+// that holds each field. The keys are datetime (or date and time), systolic, diastolic,
+// pulse, note, ihb, movement, position and zone, the last naming a column that gives the
+// time zone or offset of each reading in any form accepted by ParseZone. The keys layout,
+// datelayout and timelayout may give Go time layouts for the date time, date and time
+// columns respectively; otherwise a variety of common layouts are tried. For example:
+//
+//	datetime=Timestamp,systolic=SYS,diastolic=DIA,pulse=HR,layout=2006-01-02 15:04
+//
+// A value that contains a comma, such as the layout "Jan 2, 2006 15:04", must be enclosed
+// in double quotes, with any double quote within it doubled, as it would be in a CSV file.
+// Columns that are not named in the specification are ignored.
+func NewGenericFormat(spec string) (InputFormat, error) {
+
+	items, err := splitGenericSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	format := &columnFormat{name: "generic", columns: make(map[string]int), ignoreUnknown: true}
+	for _, item := range items {
+
+		// Split the item into its key and value, removing any quotes around the value
+		equals := strings.Index(item, "=")
+		if equals < 0 {
+			return nil, fmt.Errorf("generic format item %q is not in the form key=value", item)
+		}
+		key := strings.ToLower(strings.TrimSpace(item[:equals]))
+		value := unquoteGenericValue(strings.TrimSpace(item[equals+1:]))
+		if value == "" {
+			return nil, fmt.Errorf("generic format item %q has no value", item)
+		}
+
+		// Layouts are used as they are, everything else names a column
+		switch key {
+		case "layout":
+			format.dateTimeLayouts = []string{value}
+		case "datelayout":
+			format.dateLayouts = []string{value}
+		case "timelayout":
+			format.timeLayouts = []string{value}
+		default:
+			field, ok := genericFieldKeys[key]
+			if !ok {
+				return nil, fmt.Errorf("generic format item %q has an unknown key", item)
+			}
+			format.columns[normalizeColumnName(value)] = field
+		}
+	}
+
+	// Make sure that we have been told where to find the essential fields
+	var has [fieldCount]bool
+	for _, field := range format.columns {
+		has[field] = true
+	}
+	if !format.hasEssentialFields(has) {
+		return nil, fmt.Errorf("generic format %q must name the datetime (or date and time), systolic and diastolic columns", spec)
+	}
+	return format, nil
+}
+
+// splitGenericSpec splits a generic format specification into its items at the commas
+// that are not within double quotes.
+func splitGenericSpec(spec string) ([]string, error) {
+	var items []string
+	start, quoted := 0, false
+	for index, c := range spec {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			items = append(items, spec[start:index])
+			start = index + 1
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("generic format %q has an unterminated quoted value", spec)
+	}
+	return append(items, spec[start:]), nil
+}
+
+// unquoteGenericValue removes the double quotes from around a generic format value,
+// undoubling any within it. Values without quotes are returned as they are.
+func unquoteGenericValue(value string) string {
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return value
+	}
+	return strings.ReplaceAll(value[1:len(value)-1], `""`, `"`)
+}
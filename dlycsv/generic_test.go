@@ -0,0 +1,70 @@
+package dlycsv
+
+// Unit tests for the generic, user described, input format.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestGenericFormat converts a CSV file described by the user, with columns that no
+// registered format knows.
+func TestGenericFormat(t *testing.T) {
+
+	format, err := NewGenericFormat("datetime=Timestamp,systolic=Upper,diastolic=Lower,pulse=HR,layout=02.01.2006 15:04")
+	require.Nil(t, err, "NewGenericFormat returned an error: %v", err)
+
+	input := "Timestamp,Upper,Lower,HR,Weight\n" +
+		"28.05.2023 20:59,121,79,64,80.5\n" +
+		"28.05.2023 07:15,131,85,58,80.1\n"
+	var output bytes.Buffer
+	err = Convert(context.Background(), strings.NewReader(input), &output, Options{Format: format})
+	require.Nil(t, err, "Convert returned an error: %v", err)
+	require.Equal(t, "Date Time 1,Systolic 1,Diastolic 1,Pulse 1,Note 1,Date Time 2,Systolic 2,Diastolic 2,Pulse 2,Note 2\n"+
+		"2023-05-28 07:15:00,131,85,58,,2023-05-28 20:59:00,121,79,64,\n", output.String())
+
+	// Without being told, the columns are not recognized
+	err = Convert(context.Background(), strings.NewReader(input), &output, Options{})
+	require.Equal(t, FormatError, ClassOf(err), "expected a format error without the generic format")
+}
+
+// TestGenericFormatErrors confirms that incomplete or malformed descriptions are refused.
+func TestGenericFormatErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"datetime",
+		"datetime=",
+		"datetime=When,systolic=Sys",
+		"date=Day,systolic=Sys,diastolic=Dia",
+		"datetime=When,systolic=Sys,diastolic=Dia,weight=Kg",
+		`datetime=When,systolic=Sys,diastolic=Dia,layout="Jan 2, 2006`,
+	} {
+		_, err := NewGenericFormat(spec)
+		require.NotNil(t, err, "expected error for %q", spec)
+	}
+}
+
+// TestGenericFormatQuoted confirms that quoted values may contain commas and quotes.
+func TestGenericFormatQuoted(t *testing.T) {
+
+	format, err := NewGenericFormat(`datetime="When, local",systolic=SYS,diastolic=DIA,layout="Jan 2, 2006 15:04"`)
+	require.Nil(t, err, "NewGenericFormat returned an error: %v", err)
+	input := "\"When, local\",SYS,DIA\n" +
+		"\"May 28, 2023 07:15\",131,85\n"
+	readings, err := ParseReadings(context.Background(), strings.NewReader(input), Options{Format: format})
+	require.Nil(t, err, "ParseReadings returned an error: %v", err)
+	require.Len(t, readings, 1)
+	require.Equal(t, "2023-05-28 07:15", readings[0].Time.Format("2006-01-02 15:04"))
+
+	// Doubled quotes stand for themselves
+	require.Equal(t, `say "hi"`, unquoteGenericValue(`"say ""hi"""`))
+	require.Equal(t, `plain`, unquoteGenericValue(`plain`))
+}
@@ -0,0 +1,278 @@
+package dlycsv
+
+// The layout of the columns of the daily output, and the functions that fill
+// those columns from the readings of each day.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"strconv"
+	"time"
+)
+
+// extraField is a reading field that only some input formats provide, and that is
+// only given output columns if at least one reading has a value for it.
+type extraField struct {
+	name  string               // The column name of the field
+	value func(Reading) string // Returns the output text of the field for a reading
+}
+
+// The extra fields, in the order that their columns follow the standard columns.
+var extraFields = []extraField{
+	{name: "Irregular Heartbeat", value: func(r Reading) string { return r.IrregularHeartbeat.String() }},
+	{name: "Body Movement", value: func(r Reading) string { return r.BodyMovement.String() }},
+	{name: "Measurement Position", value: func(r Reading) string { return r.Position }},
+	{name: "Flag", value: func(r Reading) string { return r.Flag }},
+}
+
+// dailyLayout decides the columns of the daily output and how the readings of each
+// day fill them.
+type dailyLayout struct {
+	slots  []Slot       // The time slots that readings are placed in, if any
+	policy Policy       // How several readings in the same slot, or day, are resolved
+	widths []int        // The number of reading column sets for each slot, or for the whole day if there are no slots
+	extras []extraField // The extra fields that have columns in each reading column set
+	stats  bool         // True if the statistics columns follow the reading column sets
+	dates  bool         // True if each day starts with a date column, as it does when gaps are filled
+
+	rolling []int        // The lengths, in days, of the rolling average windows whose columns follow the statistics
+	groups  []DailyGroup // Every day of the output, from which the rolling averages are computed
+
+	guideline *Guideline // The guideline used to classify readings, nil if they are not classified
+}
+
+// newDailyLayout works out the layout needed for the given days and options.
+func newDailyLayout(groups []DailyGroup, opts Options) *dailyLayout {
+
+	layout := &dailyLayout{slots: opts.Slots, policy: opts.Policy, stats: opts.Stats, dates: opts.FillGaps,
+		rolling: opts.Rolling, groups: groups}
+	if opts.Classify {
+		layout.guideline = guidelineOrDefault(opts)
+	}
+
+	// Give each slot as many column sets as it needs for its busiest day,
+	// and never less than one
+	setCount := len(layout.slots)
+	if setCount == 0 {
+		setCount = 1
+	}
+	layout.widths = make([]int, setCount)
+	for index := range layout.widths {
+		layout.widths[index] = 1
+	}
+	for _, group := range groups {
+		for index, readings := range layout.columnSets(group) {
+			if len(readings) > layout.widths[index] {
+				layout.widths[index] = len(readings)
+			}
+		}
+	}
+
+	// Include the extra fields that any reading has a value for
+	for _, extra := range extraFields {
+		if anyReadingHas(groups, extra) {
+			layout.extras = append(layout.extras, extra)
+		}
+	}
+	return layout
+}
+
+// anyReadingHas returns true if any of the readings of the given days has a value
+// for the extra field.
+func anyReadingHas(groups []DailyGroup, extra extraField) bool {
+	for _, group := range groups {
+		for _, reading := range group.Readings {
+			if extra.value(reading) != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// columnSets returns the readings of the day that are to be written, once the policy has
+// been applied, as one slice for each slot or a single slice if there are no slots.
+func (l *dailyLayout) columnSets(group DailyGroup) [][]Reading {
+	if len(l.slots) == 0 {
+		return [][]Reading{l.policy.Resolve(group.Readings)}
+	}
+	return resolvedSlots(group, l.slots, l.policy)
+}
+
+// buildHeaderRecord assembles one or more sets of blood pressure CSV file column headers
+// into a string array record. Column sets are named after their slot, if there are slots,
+// and numbered where there is more than one set for a slot or, without slots, whenever
+// every reading is kept. Unless every reading is kept, the name of the policy is added to
+// each column name. The names of the statistics columns, if wanted, come next, followed
+// by those of the rolling average windows and then the daily category column if readings
+// are being classified. If each day starts with a date column, its name comes first.
+func (l *dailyLayout) buildHeaderRecord() []string {
+
+	// Build our header record here
+	var header []string
+	if l.dates {
+		header = append(header, "Date")
+	}
+
+	// Loop through the slots, or the whole day, adding their header sections
+	for index, width := range l.widths {
+		prefix := ""
+		if len(l.slots) > 0 {
+			prefix = l.slots[index].Name + " "
+		}
+		numbered := width > 1 || (len(l.slots) == 0 && l.policy == KeepAll)
+
+		// We want to start our numbering at 1
+		for set := 1; set <= width; set++ {
+			suffix := ""
+			if numbered {
+				suffix = " " + strconv.Itoa(set)
+			}
+			l.addHeadingSet(&header, prefix, suffix+policySuffix(l.policy))
+		}
+	}
+
+	// Add the statistics columns if they are wanted
+	if l.stats {
+		header = append(header, statsHeadings...)
+	}
+	header = append(header, rollingHeadingsFor(l.rolling)...)
+	if l.guideline != nil {
+		header = append(header, "Daily Category")
+	}
+
+	// And we have our finished header
+	return header
+}
+
+// addHeadingSet appends one set of column names, each with the given prefix
+// and suffix, to the header record
+func (l *dailyLayout) addHeadingSet(header *[]string, prefix, suffix string) {
+
+	// Build an array of heading names
+	headingSet := []string{
+		prefix + "Date Time" + suffix,
+		prefix + "Systolic" + suffix,
+		prefix + "Diastolic" + suffix,
+		prefix + "Pulse" + suffix,
+		prefix + "Note" + suffix,
+	}
+	for _, extra := range l.extras {
+		headingSet = append(headingSet, prefix+extra.name+suffix)
+	}
+	if l.guideline != nil {
+		headingSet = append(headingSet, prefix+"Category"+suffix)
+	}
+
+	// Add the set to the header
+	*header = append(*header, headingSet...)
+}
+
+// policySuffix returns the text added to column names to record the policy used to
+// resolve their readings, nothing if all readings are kept.
+func policySuffix(policy Policy) string {
+	if policy == KeepAll {
+		return ""
+	}
+	return " (" + policy.String() + ")"
+}
+
+// buildDailyRecord concatenates the fields of the readings of a day into a single record.
+// When there are slots, each slot is padded with blank columns where it has fewer readings
+// than it has column sets, so that every slot always occupies the same columns.
+func (l *dailyLayout) buildDailyRecord(group DailyGroup) []string {
+	return cellsToStrings(l.buildDailyCells(group))
+}
+
+// buildDailyCells does the work of buildDailyRecord, returning typed cells rather than text.
+// If the statistics, rolling average or daily category columns are wanted, every day is
+// padded to the full width so that they always line up. The daily category is that of the
+// mean pressures of the readings described by the statistics.
+func (l *dailyLayout) buildDailyCells(group DailyGroup) []cell {
+	var record []cell
+	if l.dates {
+		record = append(record, dateValue(group.Date))
+	}
+	for index, readings := range l.columnSets(group) {
+		for set := 0; set < l.widths[index]; set++ {
+			if set < len(readings) {
+				record = append(record, l.readingCells(readings[set])...)
+			} else if len(l.slots) > 0 || l.stats || len(l.rolling) > 0 || l.guideline != nil {
+				record = append(record, make([]cell, l.readingFieldCount())...)
+			}
+		}
+	}
+	if l.stats || len(l.rolling) > 0 || l.guideline != nil {
+		stats := ComputeStats(l.dayReadings(group))
+		if l.stats {
+			record = append(record, stats.cells()...)
+		}
+		record = append(record, l.rollingCells(group.Date)...)
+		if l.guideline != nil {
+			category := cell{}
+			if stats.Count > 0 {
+				category = textValue(l.guideline.Label(l.guideline.ClassifyStats(stats)))
+			}
+			record = append(record, category)
+		}
+	}
+	return record
+}
+
+// dayReadings returns the readings of the day that fall within the slots, or all of the
+// readings of the day if there are no slots, before any policy is applied. These are the
+// readings that the statistics columns describe.
+func (l *dailyLayout) dayReadings(group DailyGroup) []Reading {
+	if len(l.slots) == 0 {
+		return group.Readings
+	}
+	var readings []Reading
+	for _, slotReadings := range group.BySlot(l.slots) {
+		readings = append(readings, slotReadings...)
+	}
+	return readings
+}
+
+// rollingCells returns the cells of the rolling average windows that end on the given day,
+// computed from the same readings of each day as the statistics.
+func (l *dailyLayout) rollingCells(end time.Time) []cell {
+	var cells []cell
+	for _, days := range l.rolling {
+		cells = append(cells, rolling(l.groups, end, days, l.dayReadings).cells()...)
+	}
+	return cells
+}
+
+// readingFieldCount returns the number of columns that each reading occupies.
+func (l *dailyLayout) readingFieldCount() int {
+	count := 5 + len(l.extras)
+	if l.guideline != nil {
+		count++
+	}
+	return count
+}
+
+// readingCells returns the output cells of a single reading, leaving the pulse blank
+// if it was not recorded.
+func (l *dailyLayout) readingCells(reading Reading) []cell {
+	pulse := cell{}
+	if reading.Pulse != 0 {
+		pulse = intValue(reading.Pulse)
+	}
+	cells := []cell{
+		timeValue(reading.Time),
+		intValue(reading.Systolic),
+		intValue(reading.Diastolic),
+		pulse,
+		textValue(reading.Note),
+	}
+	for _, extra := range l.extras {
+		cells = append(cells, textValue(extra.value(reading)))
+	}
+	if l.guideline != nil {
+		cells = append(cells, textValue(l.guideline.Label(l.guideline.ClassifyReading(reading))))
+	}
+	return cells
+}
@@ -0,0 +1,177 @@
+package dlycsv
+
+// The merging of readings from several inputs, such as overlapping exports of the
+// same history, into a single set of readings without duplicates.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// MergeResult describes the readings that MergeReadings left out.
+type MergeResult struct {
+	Duplicates int      // The number of readings left out because they repeated an earlier reading
+	Conflicts  []Reject // The readings left out because an earlier reading had the same time but different values
+}
+
+// MergeReadings removes the readings that repeat an earlier reading, returning the rest in
+// their original order. A reading repeats another if it was taken at the same time with the
+// same pressures and pulse; the earlier reading is kept, taking the note of the repeat if it
+// has none of its own. A reading taken at the same time as an earlier reading but with
+// different values conflicts with it: the earlier reading is kept and the conflict reported.
+func MergeReadings(readings []Reading) ([]Reading, MergeResult) {
+
+	var result MergeResult
+	var merged []Reading
+	seen := make(map[int64]int)
+	for _, reading := range readings {
+
+		// Keep the reading if it is the first at its time
+		key := reading.Time.Unix()
+		index, found := seen[key]
+		if !found {
+			seen[key] = len(merged)
+			merged = append(merged, reading)
+			continue
+		}
+
+		// Otherwise leave it out, noting why
+		earlier := &merged[index]
+		if sameValues(*earlier, reading) {
+			result.Duplicates++
+			if earlier.Note == "" {
+				earlier.Note = reading.Note
+			}
+			continue
+		}
+		result.Conflicts = append(result.Conflicts, Reject{
+			Source: reading.Source,
+			Line:   reading.Line,
+			Text:   reading.Text,
+			Reason: fmt.Sprintf("%d/%d pulse %d conflicts with %d/%d pulse %d at the same time in %s line %d",
+				reading.Systolic, reading.Diastolic, reading.Pulse,
+				earlier.Systolic, earlier.Diastolic, earlier.Pulse, earlier.Source, earlier.Line),
+		})
+	}
+	return merged, result
+}
+
+// sameValues returns true if the two readings have the same pressures and pulse.
+func sameValues(a, b Reading) bool {
+	return a.Systolic == b.Systolic && a.Diastolic == b.Diastolic && a.Pulse == b.Pulse
+}
+
+// ExpandInputPaths expands the given input paths into the paths of the files that they
+// name. A path containing the wildcards of filepath.Match is replaced by the files that
+// match it, and a directory by the files within it, in name order, leaving out those whose
+// names begin with a dot. Any other path, including StdioPath, is kept as it is. An error
+// is returned if a wildcard or directory yields no files.
+func ExpandInputPaths(inputPaths []string) ([]string, error) {
+	var expanded []string
+	for _, inputPath := range inputPaths {
+
+		// Expand wildcards
+		if inputPath != StdioPath && strings.ContainsAny(inputPath, "*?[") {
+			matches, err := filepath.Glob(inputPath)
+			if err != nil {
+				return nil, classifiedErrorf(InputError, "invalid input file pattern %q: %w", inputPath, err)
+			} else if len(matches) == 0 {
+				return nil, classifiedErrorf(InputError, "no input files match %q", inputPath)
+			}
+			sort.Strings(matches)
+			expanded = append(expanded, matches...)
+			continue
+		}
+
+		// Expand directories
+		if info, err := os.Stat(inputPath); err == nil && info.IsDir() {
+			files, err := directoryFiles(inputPath)
+			if err != nil {
+				return nil, err
+			}
+			expanded = append(expanded, files...)
+			continue
+		}
+		expanded = append(expanded, inputPath)
+	}
+	return expanded, nil
+}
+
+// directoryFiles returns the paths of the files in the given directory, in name order,
+// leaving out those whose names begin with a dot.
+func directoryFiles(directory string) ([]string, error) {
+	entries, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return nil, classifiedErrorf(InputError, "could not read input directory: %w", err)
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.Mode().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+			files = append(files, filepath.Join(directory, entry.Name()))
+		}
+	}
+	if len(files) == 0 {
+		return nil, classifiedErrorf(InputError, "input directory %s contains no files", directory)
+	}
+	return files, nil
+}
+
+// parseFiles expands the input paths, parses each of the files that they name, and
+// merges the readings of every file together if there is more than one, so that a single
+// file keeps every reading that it holds. If the options do not name a source, each file
+// is named by its path. The rejects include those of every file, followed by any conflicts
+// found while merging.
+func parseFiles(ctx context.Context, inputPaths []string, opts Options) ([]Reading, []Reject, int, error) {
+
+	// Find the files that we have been asked to read
+	paths, err := ExpandInputPaths(inputPaths)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	// Parse each file in turn
+	var readings []Reading
+	var rejects []Reject
+	for _, path := range paths {
+		fileReadings, fileRejects, err := parseFile(ctx, path, opts)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		readings = append(readings, fileReadings...)
+		rejects = append(rejects, fileRejects...)
+	}
+
+	// Merge the readings of the files together, if there is more than one
+	if len(paths) < 2 {
+		return readings, rejects, 0, nil
+	}
+	merged, result := MergeReadings(readings)
+	return merged, append(rejects, result.Conflicts...), result.Duplicates, nil
+}
+
+// parseFile parses the readings of a single input file, or of standard input if the
+// path is StdioPath.
+func parseFile(ctx context.Context, inputPath string, opts Options) ([]Reading, []Reject, error) {
+
+	// Open the input file
+	inputFile, err := openInputFile(inputPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer inputFile.Close()
+
+	// Name the file as the source, unless the options say otherwise, and parse it
+	if opts.Source == "" {
+		opts.Source = sourceName(inputPath)
+	}
+	return parseReadings(ctx, inputFile, opts)
+}
@@ -0,0 +1,116 @@
+package dlycsv
+
+// Unit tests for the merging of readings from several inputs.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestMergeReadings confirms that repeats are left out and conflicts reported.
+func TestMergeReadings(t *testing.T) {
+	at := time.Date(2020, 5, 1, 7, 0, 0, 0, time.UTC)
+	readings := []Reading{
+		{Time: at, Systolic: 120, Diastolic: 80, Pulse: 60, Source: "a.csv", Line: 2},
+		{Time: at.Add(time.Hour), Systolic: 125, Diastolic: 82, Pulse: 61, Source: "a.csv", Line: 3},
+		{Time: at, Systolic: 120, Diastolic: 80, Pulse: 60, Note: "after coffee", Source: "b.csv", Line: 2},
+		{Time: at.Add(time.Hour), Systolic: 135, Diastolic: 82, Pulse: 61, Source: "b.csv", Line: 3},
+	}
+	merged, result := MergeReadings(readings)
+	require.Len(t, merged, 2, "the repeat and the conflict should be left out")
+	require.Equal(t, "a.csv", merged[0].Source, "the earlier reading should be kept")
+	require.Equal(t, "after coffee", merged[0].Note, "the note of the repeat should be taken")
+	require.Equal(t, 125, merged[1].Systolic)
+	require.Equal(t, 1, result.Duplicates)
+	require.Equal(t, []Reject{{
+		Source: "b.csv",
+		Line:   3,
+		Reason: "135/82 pulse 61 conflicts with 125/82 pulse 61 at the same time in a.csv line 3",
+	}}, result.Conflicts)
+}
+
+// TestExpandInputPaths confirms that wildcards and directories are expanded.
+func TestExpandInputPaths(t *testing.T) {
+	expected := []string{"../testdata/merge/export-2020-05-01.csv", "../testdata/merge/export-2020-05-03.csv"}
+	paths, err := ExpandInputPaths([]string{"../testdata/merge"})
+	require.Nil(t, err, "ExpandInputPaths returned an error: %v", err)
+	require.Equal(t, expected, paths)
+	paths, err = ExpandInputPaths([]string{"../testdata/merge/*.csv", StdioPath, "plain.csv"})
+	require.Nil(t, err, "ExpandInputPaths returned an error: %v", err)
+	require.Equal(t, append(expected, StdioPath, "plain.csv"), paths)
+
+	// Wildcards and directories that find nothing are input errors
+	_, err = ExpandInputPaths([]string{"../testdata/merge/*.xml"})
+	require.Equal(t, InputError, ClassOf(err), "an unmatched pattern should be an input error")
+	dir, err := ioutil.TempDir("", "bpdaily-merge")
+	require.Nil(t, err, "could not create temporary directory: %v", err)
+	defer os.RemoveAll(dir)
+	_, err = ExpandInputPaths([]string{dir})
+	require.Equal(t, InputError, ClassOf(err), "an empty directory should be an input error")
+}
+
+// TestConvertFiles confirms that overlapping exports are merged into a single daily file.
+func TestConvertFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bpdaily-merge")
+	require.Nil(t, err, "could not create temporary directory: %v", err)
+	defer os.RemoveAll(dir)
+	outputPath := filepath.Join(dir, "daily.csv")
+
+	var rejects []Reject
+	opts := Options{Policy: KeepFirst, Rejected: func(reject Reject) { rejects = append(rejects, reject) }}
+	err = ConvertFiles([]string{"../testdata/merge"}, outputPath, false, opts)
+	require.Nil(t, err, "ConvertFiles returned an error: %v", err)
+	content, err := ioutil.ReadFile(outputPath)
+	require.Nil(t, err, "could not read output file: %v", err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, lines, 6, "a header and five days were expected")
+	require.Equal(t, "2020-04-29 18:42:54,101,77,63,Second reading", lines[1], "the note of the later export should be taken")
+
+	// The edited reading conflicts with the earlier export
+	require.Len(t, rejects, 1)
+	require.Equal(t, "../testdata/merge/export-2020-05-03.csv", rejects[0].Source)
+	require.Equal(t, 7, rejects[0].Line)
+	require.Equal(t, "Apr 30 2020 05:41:59,106,70,52,Edited", rejects[0].Text, "the conflict should show the losing record")
+
+	// The summary counts the repeats
+	summary, err := SummarizeFiles([]string{"../testdata/merge/export-2020-05-01.csv", "../testdata/merge/export-2020-05-03.csv"}, Options{})
+	require.Nil(t, err, "SummarizeFiles returned an error: %v", err)
+	require.Equal(t, 7, summary.Readings)
+	require.Equal(t, 3, summary.Duplicates)
+	require.Equal(t, 1, summary.Discarded, "the conflict should be counted as discarded")
+}
+
+// TestSingleInputNotMerged confirms that the readings of a single input are all kept, even
+// those that share a time, since only the readings of several inputs are merged.
+func TestSingleInputNotMerged(t *testing.T) {
+	input := "Date Time,Systolic,Diastolic,Pulse,Note\n" +
+		"May 01 2020 06:22:09,96,66,53,\n" +
+		"May 01 2020 06:22:09,96,66,53,\n" +
+		"May 01 2020 06:22:09,99,68,54,\n"
+	summary, err := Summarize(context.Background(), strings.NewReader(input), Options{})
+	require.Nil(t, err, "Summarize returned an error: %v", err)
+	require.Equal(t, 3, summary.Readings)
+	require.Zero(t, summary.Duplicates)
+	require.Zero(t, summary.Discarded)
+
+	// Nor are those of a single input file
+	dir, err := ioutil.TempDir("", "bpdaily-merge")
+	require.Nil(t, err, "could not create temporary directory: %v", err)
+	defer os.RemoveAll(dir)
+	inputPath := filepath.Join(dir, "history.csv")
+	require.Nil(t, ioutil.WriteFile(inputPath, []byte(input), 0644))
+	summary, err = SummarizeFile(inputPath, Options{})
+	require.Nil(t, err, "SummarizeFile returned an error: %v", err)
+	require.Equal(t, 3, summary.Readings)
+}
@@ -0,0 +1,49 @@
+package dlycsv
+
+// The input format of the CSV files exported by the Omron blood pressure apps, both
+// the original five column format and the newer Omron Connect formats with their
+// extra columns and various date formats.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+// OmronFormat is the InputFormat of the CSV files exported by the Omron apps.
+var OmronFormat InputFormat = &columnFormat{
+	name:         "omron",
+	requirePulse: true,
+	columns: map[string]int{
+		"date time":                          fieldDateTime,
+		"measurement date":                   fieldDateTime,
+		"date":                               fieldDate,
+		"time":                               fieldTime,
+		"systolic":                           fieldSystolic,
+		"sys":                                fieldSystolic,
+		"diastolic":                          fieldDiastolic,
+		"dia":                                fieldDiastolic,
+		"pulse":                              fieldPulse,
+		"pulse rate":                         fieldPulse,
+		"note":                               fieldNote,
+		"notes":                              fieldNote,
+		"memo":                               fieldNote,
+		"irregular heartbeat":                fieldIrregularHeartbeat,
+		"irregular heartbeat detected":       fieldIrregularHeartbeat,
+		"irregular heart beat":               fieldIrregularHeartbeat,
+		"ihb":                                fieldIrregularHeartbeat,
+		"body movement":                      fieldBodyMovement,
+		"body movement detected":             fieldBodyMovement,
+		"measurement position":               fieldPosition,
+		"measurement position indicator":     fieldPosition,
+		"positioning indicator":              fieldPosition,
+		"cuff wrap guide":                    fieldIgnored,
+		"cuff wrap guide indicator":          fieldIgnored,
+		"device":                             fieldIgnored,
+		"time zone":                          fieldZone,
+		"afib":                               fieldIgnored,
+		"possible afib":                      fieldIgnored,
+		"measurement mode":                   fieldIgnored,
+		"morning hypertension":               fieldIgnored,
+		"hypertension":                       fieldIgnored,
+		"irregular heartbeat detected count": fieldIgnored,
+	},
+}
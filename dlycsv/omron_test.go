@@ -0,0 +1,121 @@
+package dlycsv
+
+// Unit tests for the recognition and parsing of Omron CSV exports.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestOmronConnect converts a newer Omron Connect export, with separate date and time
+// columns, units in the column names and extra indicator columns.
+func TestOmronConnect(t *testing.T) {
+
+	// Make sure the output file does not exist
+	filePaths := buildTestFilePaths("../testdata/omronconnect")
+	err := removeFile(filePaths.OutputPath)
+	require.Nil(t, err, "could not delete output file: %v", err)
+
+	// Run the target function
+	err = ConvertBloodPressureCSVToDaily(filePaths.InputPath, filePaths.OutputPath, false)
+	require.Nil(t, err, "ConvertBloodPressureCSVToDaily returned an error: %v", err)
+
+	// Confirm that the output obtained matches that expected
+	err = outputIsAsExpected(filePaths)
+	require.Nil(t, err, "output content did not match expected: %v", err)
+}
+
+// TestDetectOmronColumns confirms which header records are recognized.
+func TestDetectOmronColumns(t *testing.T) {
+
+	// Headers that should be recognized
+	for _, header := range [][]string{
+		{"Date Time", "Systolic", "Diastolic", "Pulse", "Note"},
+		{"Measurement Date", "SYS(mmHg)", "DIA(mmHg)", "Pulse Rate (bpm)"},
+		{"Date", "Time", "Systolic", "Diastolic", "Pulse", "IHB", "Device"},
+	} {
+		_, ok := OmronFormat.Detect(header)
+		require.True(t, ok, "expected %v to be recognized", header)
+	}
+
+	// Headers that should not be recognized
+	for _, header := range [][]string{
+		{"Date Time", "Systolic", "Diastolic", "Pulse", "Wrong Heading"},
+		{"Date Time", "Systolic", "Diastolic", "Note"},
+		{"Date", "Systolic", "Diastolic", "Pulse"},
+		{"Date Time", "Systolic", "Systolic", "Diastolic", "Pulse"},
+	} {
+		_, ok := OmronFormat.Detect(header)
+		require.False(t, ok, "expected %v not to be recognized", header)
+	}
+}
+
+// TestOmronDateFormats confirms that the various Omron date formats are understood.
+func TestOmronDateFormats(t *testing.T) {
+	expected := time.Date(2023, 5, 28, 20, 59, 0, 0, time.UTC)
+	for _, text := range []string{
+		"May 28 2023 20:59:00",
+		"May 28, 2023 8:59 PM",
+		"2023-05-28 20:59",
+		"2023/05/28 20:59:00",
+		"5/28/2023 20:59",
+		"5/28/2023 8:59 PM",
+	} {
+		parsed, err := parseWithLayouts(defaultDateTimeLayouts, text)
+		require.Nil(t, err, "could not parse %q: %v", text, err)
+		require.Equal(t, expected, parsed, "wrong time for %q", text)
+	}
+
+	// Separate date and time columns
+	parser := &columnParser{format: OmronFormat.(*columnFormat)}
+	parsed, err := parser.parseDateAndTime("May 28, 2023", "8:59 PM")
+	require.Nil(t, err, "could not parse separate date and time: %v", err)
+	require.Equal(t, expected, parsed, "wrong time for separate date and time")
+}
+
+// TestOmronTimeZones confirms that the Time Zone column of an Omron Connect export places
+// readings taken abroad on the right day of the display zone.
+func TestOmronTimeZones(t *testing.T) {
+	newYork := mustZone(t, "America/New_York")
+	opts := Options{InputZone: newYork, DisplayZone: newYork}
+	summary, err := SummarizeFile("../testdata/omronzone.in.csv", opts)
+	require.Nil(t, err, "SummarizeFile returned an error: %v", err)
+	require.Equal(t, 4, summary.Readings)
+	require.Zero(t, summary.Discarded, "every zone should have been understood")
+
+	// The mornings in Tokyo were the evenings before in New York
+	readings, rejects, _, err := parseFiles(context.Background(), []string{"../testdata/omronzone.in.csv"}, opts)
+	require.Nil(t, err, "parseFiles returned an error: %v", err)
+	require.Empty(t, rejects)
+	var days []string
+	for _, group := range GroupByDay(readings) {
+		days = append(days, group.Date.Format("2006-01-02"))
+	}
+	require.Equal(t, []string{"2023-05-28", "2023-05-29", "2023-05-31"}, days)
+	for _, reading := range readings {
+		switch reading.Systolic {
+		case 124:
+			require.Equal(t, "2023-05-29 18:30", reading.Time.Format("2006-01-02 15:04"))
+		case 122:
+			require.Equal(t, "2023-05-28 18:30", reading.Time.Format("2006-01-02 15:04"))
+		case 119:
+			require.Equal(t, "2023-05-31 08:00", reading.Time.Format("2006-01-02 15:04"), "UTC should not be taken as the input zone")
+		}
+	}
+}
+
+// TestParseDetection confirms how indicator values are interpreted.
+func TestParseDetection(t *testing.T) {
+	require.Equal(t, NotRecorded, parseDetection(" - "))
+	require.Equal(t, NotDetected, parseDetection("No"))
+	require.Equal(t, Detected, parseDetection("Yes"))
+	require.Equal(t, Detected, parseDetection("1"))
+	require.Equal(t, "", NotRecorded.String())
+}
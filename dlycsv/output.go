@@ -0,0 +1,85 @@
+package dlycsv
+
+// The output formats in which the daily readings can be written.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// OutputFormat selects the format in which the daily readings are written. The zero
+// value, AutoOutput, chooses the format from the extension of the output file path,
+// writing CSV if the extension is not recognized or there is no output file path.
+type OutputFormat int
+
+// The supported output formats.
+const (
+	AutoOutput OutputFormat = iota // Choose the format from the output file path
+	CSVOutput                      // CSV data, one line per day
+	XLSXOutput                     // An Excel workbook with a daily sheet and a readings sheet
+	SVGOutput                      // An SVG chart of the readings
+	HTMLOutput                     // A self-contained HTML report with a chart and tables
+)
+
+// The names of the output formats, as accepted by ParseOutputFormat, which are also the
+// file path extensions that select them.
+var outputFormatNames = []string{"auto", "csv", "xlsx", "svg", "html"}
+
+// String returns the name of the output format.
+func (f OutputFormat) String() string {
+	if f < 0 || int(f) >= len(outputFormatNames) {
+		return fmt.Sprintf("OutputFormat(%d)", int(f))
+	}
+	return outputFormatNames[f]
+}
+
+// ParseOutputFormat returns the output format with the given name: one of auto, csv,
+// xlsx, svg or html.
+func ParseOutputFormat(name string) (OutputFormat, error) {
+	for index, formatName := range outputFormatNames {
+		if strings.EqualFold(name, formatName) {
+			return OutputFormat(index), nil
+		}
+	}
+	return AutoOutput, fmt.Errorf("unknown output format %q, expected one of %s", name, strings.Join(outputFormatNames, ", "))
+}
+
+// OutputFormatFor returns the output format selected by the extension of the given file
+// path, CSVOutput if the extension is not that of any other format.
+func OutputFormatFor(path string) OutputFormat {
+	extension := strings.TrimPrefix(filepath.Ext(path), ".")
+	if format, err := ParseOutputFormat(extension); err == nil && format != AutoOutput {
+		return format
+	}
+	return CSVOutput
+}
+
+// WriteOutput writes the given daily groups to w in the output format selected by the
+// options, CSV if the options leave the choice to AutoOutput. If the options GroupBy a
+// period longer than a day, only CSV and XLSX output are possible.
+func WriteOutput(w io.Writer, groups []DailyGroup, opts Options) error {
+	if opts.GroupBy != ByDay {
+		switch opts.Output {
+		case XLSXOutput:
+			return WriteXLSX(w, groups, opts)
+		case SVGOutput, HTMLOutput:
+			return classifiedErrorf(OutputError, "%s output cannot be grouped by %s, only csv or xlsx output can", opts.Output, opts.GroupBy)
+		}
+		return WritePeriods(w, groups, opts)
+	}
+	switch opts.Output {
+	case XLSXOutput:
+		return WriteXLSX(w, groups, opts)
+	case SVGOutput:
+		return WriteSVG(w, groups, opts)
+	case HTMLOutput:
+		return WriteHTML(w, groups, opts)
+	}
+	return WriteDaily(w, groups, opts)
+}
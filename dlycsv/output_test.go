@@ -0,0 +1,26 @@
+package dlycsv
+
+// Unit tests for the selection of output formats.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestOutputFormatFor confirms that the output format is chosen by file extension.
+func TestOutputFormatFor(t *testing.T) {
+	require.Equal(t, XLSXOutput, OutputFormatFor("daily.XLSX"))
+	require.Equal(t, CSVOutput, OutputFormatFor("daily.csv"))
+	require.Equal(t, CSVOutput, OutputFormatFor("daily"))
+	require.Equal(t, CSVOutput, OutputFormatFor("daily.auto"))
+	require.Equal(t, SVGOutput, OutputFormatFor("chart.svg"))
+	require.Equal(t, HTMLOutput, OutputFormatFor("report.html"))
+
+	_, err := ParseOutputFormat("pdf")
+	require.NotNil(t, err, "expected error for an unknown output format")
+}
@@ -0,0 +1,182 @@
+package dlycsv
+
+// The aggregation of days into longer periods, such as weeks and months, so that
+// trends over a year or more can be charted from one row per period.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Period selects the length of the periods into which the daily output is aggregated.
+// The zero value, ByDay, writes one line per day with every reading.
+type Period int
+
+// The supported periods.
+const (
+	ByDay     Period = iota // One line per day, with the readings of the day
+	ByWeek                  // One line per week, starting on Sunday
+	ByISOWeek               // One line per ISO 8601 week, starting on Monday
+	ByMonth                 // One line per calendar month
+)
+
+// The names of the periods, as accepted by ParsePeriod.
+var periodNames = []string{"day", "week", "iso-week", "month"}
+
+// String returns the name of the period.
+func (p Period) String() string {
+	if p < 0 || int(p) >= len(periodNames) {
+		return fmt.Sprintf("Period(%d)", int(p))
+	}
+	return periodNames[p]
+}
+
+// ParsePeriod returns the period with the given name: one of day, week, iso-week or month.
+func ParsePeriod(name string) (Period, error) {
+	for index, periodName := range periodNames {
+		if strings.EqualFold(name, periodName) {
+			return Period(index), nil
+		}
+	}
+	return ByDay, fmt.Errorf("unknown period %q, expected one of %s", name, strings.Join(periodNames, ", "))
+}
+
+// PeriodGroup gathers the days of a single period.
+type PeriodGroup struct {
+	Label string       // The name of the period: its first day for days and weeks, such as "2020-04-26", "2020-W18" for ISO weeks, or "2020-05" for months
+	Start time.Time    // Midnight at the start of the first day of the period
+	End   time.Time    // Midnight at the start of the last day of the period
+	Days  []DailyGroup // The days of the period that were given, in ascending date order
+}
+
+// GroupByPeriod gathers the given daily groups, which must be in ascending date order,
+// into one group for each period in which there is a day, in ascending order. The start
+// and end of each period are those of the whole period, even if only some of its days
+// were given.
+func GroupByPeriod(groups []DailyGroup, period Period) []PeriodGroup {
+	var periods []PeriodGroup
+	for _, group := range groups {
+		start := period.start(group.Date)
+		if count := len(periods); count == 0 || daysBetween(periods[count-1].Start, start) != 0 {
+			periods = append(periods, PeriodGroup{Label: period.label(start), Start: start, End: period.end(start)})
+		}
+		latest := &periods[len(periods)-1]
+		latest.Days = append(latest.Days, group)
+	}
+	return periods
+}
+
+// start returns midnight at the start of the first day of the period that includes the
+// given day.
+func (p Period) start(day time.Time) time.Time {
+	day = startOfDay(day)
+	switch p {
+	case ByWeek:
+		return startOfDay(day.AddDate(0, 0, -int(day.Weekday())))
+	case ByISOWeek:
+		return startOfDay(day.AddDate(0, 0, -(int(day.Weekday())+6)%7))
+	case ByMonth:
+		return startOfDay(day.AddDate(0, 0, 1-day.Day()))
+	}
+	return day
+}
+
+// end returns midnight at the start of the last day of the period that starts on the
+// given day.
+func (p Period) end(start time.Time) time.Time {
+	switch p {
+	case ByWeek, ByISOWeek:
+		return startOfDay(start.AddDate(0, 0, 6))
+	case ByMonth:
+		return startOfDay(start.AddDate(0, 1, -1))
+	}
+	return start
+}
+
+// label returns the name of the period that starts on the given day.
+func (p Period) label(start time.Time) string {
+	switch p {
+	case ByISOWeek:
+		year, week := start.ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", year, week)
+	case ByMonth:
+		return start.Format("2006-01")
+	}
+	return start.Format(dateLayout)
+}
+
+// buildPeriodHeaderRecord returns the column names of the aggregated output: the period,
+// its first and last days, the number of days with readings, the statistics and trend
+// columns, the rolling average columns and, if readings are being classified, the category
+// of the period's mean pressures.
+func (l *dailyLayout) buildPeriodHeaderRecord() []string {
+	header := []string{"Period", "Start", "End", "Days With Readings"}
+	header = append(header, statsHeadings...)
+	header = append(header, trendHeadings...)
+	header = append(header, rollingHeadingsFor(l.rolling)...)
+	if l.guideline != nil {
+		header = append(header, "Category")
+	}
+	return header
+}
+
+// buildPeriodCells returns the output cells of a period. The statistics describe every
+// reading of the period within the slots, as the statistics columns of the daily output
+// do, before any policy is applied.
+func (l *dailyLayout) buildPeriodCells(period PeriodGroup) []cell {
+	var readings []Reading
+	days := 0
+	for _, group := range period.Days {
+		dayReadings := l.dayReadings(group)
+		if len(dayReadings) > 0 {
+			days++
+		}
+		readings = append(readings, dayReadings...)
+	}
+	stats := ComputeStats(readings)
+	record := []cell{textValue(period.Label), dateValue(period.Start), dateValue(period.End), intValue(days)}
+	record = append(record, stats.cells()...)
+	record = append(record, ComputeTrend(readings).cells()...)
+	record = append(record, l.rollingCells(period.End)...)
+	if l.guideline != nil {
+		category := cell{}
+		if stats.Count > 0 {
+			category = textValue(l.guideline.Label(l.guideline.ClassifyStats(stats)))
+		}
+		record = append(record, category)
+	}
+	return record
+}
+
+// WritePeriods writes the given daily groups to w as CSV data, aggregated into one line per
+// period of the GroupBy of the options, preceded by a header record. Each line gives the
+// period, its first and last days, the number of days with readings, and the same
+// statistics as the statistics columns of WriteDaily, computed from every reading of the
+// period within the slots, with the slopes of the least-squares trends of those readings.
+// The rolling averages of the options follow, for the windows that end on the last day of
+// each period, then the category of the period's mean pressures if the options ask to
+// Classify. If they ask to FillGaps, periods without any readings are written too.
+func WritePeriods(w io.Writer, groups []DailyGroup, opts Options) error {
+
+	// Decide on how each period is to be laid out
+	groups = fillGaps(groups, opts)
+	layout := newDailyLayout(groups, opts)
+
+	// Write the header record, then one record per period
+	writer := csv.NewWriter(w)
+	records := [][]string{layout.buildPeriodHeaderRecord()}
+	for _, period := range GroupByPeriod(groups, opts.GroupBy) {
+		records = append(records, cellsToStrings(layout.buildPeriodCells(period)))
+	}
+	if err := writer.WriteAll(records); err != nil {
+		return classifiedErrorf(OutputError, "failed to write blood pressure data to output file: %w", err)
+	}
+	return nil
+}
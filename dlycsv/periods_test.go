@@ -0,0 +1,102 @@
+package dlycsv
+
+// Unit tests for the aggregation of days into weeks and months.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestParsePeriod confirms that periods are parsed by name, whatever their case.
+func TestParsePeriod(t *testing.T) {
+	for _, period := range []Period{ByDay, ByWeek, ByISOWeek, ByMonth} {
+		parsed, err := ParsePeriod(strings.ToUpper(period.String()))
+		require.Nil(t, err, "ParsePeriod returned an error: %v", err)
+		require.Equal(t, period, parsed)
+	}
+	_, err := ParsePeriod("fortnight")
+	require.NotNil(t, err, "an unknown period should have been refused")
+}
+
+// TestGroupByPeriod confirms the boundaries and labels of each kind of period.
+func TestGroupByPeriod(t *testing.T) {
+
+	// Thursday 31st December 2020 to Monday 4th January 2021
+	var readings []Reading
+	for day := 0; day < 5; day++ {
+		readings = append(readings, Reading{Time: time.Date(2020, 12, 31+day, 7, 0, 0, 0, time.UTC), Systolic: 120, Diastolic: 80})
+	}
+	groups := GroupByDay(readings)
+
+	// Weeks start on Sunday
+	periods := GroupByPeriod(groups, ByWeek)
+	require.Len(t, periods, 2)
+	require.Equal(t, "2020-12-27", periods[0].Label)
+	require.Equal(t, time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC), periods[0].End)
+	require.Len(t, periods[0].Days, 3)
+	require.Equal(t, "2021-01-03", periods[1].Label)
+
+	// ISO weeks start on Monday and may belong to the year before
+	periods = GroupByPeriod(groups, ByISOWeek)
+	require.Len(t, periods, 2)
+	require.Equal(t, "2020-W53", periods[0].Label)
+	require.Equal(t, time.Date(2020, 12, 28, 0, 0, 0, 0, time.UTC), periods[0].Start)
+	require.Len(t, periods[0].Days, 4)
+	require.Equal(t, "2021-W01", periods[1].Label)
+
+	// Months run from the first to the last day, whatever days were given
+	periods = GroupByPeriod(groups, ByMonth)
+	require.Len(t, periods, 2)
+	require.Equal(t, "2020-12", periods[0].Label)
+	require.Equal(t, time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC), periods[0].Start)
+	require.Equal(t, time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC), periods[0].End)
+	require.Equal(t, time.Date(2021, 1, 31, 0, 0, 0, 0, time.UTC), periods[1].End)
+	require.Len(t, periods[1].Days, 4)
+
+	// Days are periods of their own
+	require.Len(t, GroupByPeriod(groups, ByDay), 5)
+}
+
+// TestWritePeriods confirms the aggregated CSV output, with and without gaps filled.
+func TestWritePeriods(t *testing.T) {
+	readings := append(gapReadings(), Reading{Time: time.Date(2020, 5, 9, 20, 0, 0, 0, time.UTC), Systolic: 140, Diastolic: 90, Pulse: 70})
+
+	var output bytes.Buffer
+	opts := Options{GroupBy: ByISOWeek, Classify: true}
+	require.Nil(t, WriteOutput(&output, GroupByDay(readings), opts))
+	require.Equal(t, "Period,Start,End,Days With Readings,"+strings.Join(statsHeadings, ",")+",Systolic Trend,Diastolic Trend,Pulse Trend,Category\n"+
+		"2020-W18,2020-04-27,2020-05-03,2,120,120,120,80,80,80,60,2,40,93.3,0,0,0,Stage 1 Hypertension\n"+
+		"2020-W19,2020-05-04,2020-05-10,2,126.7,120,140,83.3,80,90,63.3,3,43.3,97.8,2.75,1.38,1.38,Stage 1 Hypertension\n",
+		output.String())
+
+	// Filled gaps give periods without readings a line of their own
+	output.Reset()
+	opts = Options{GroupBy: ByWeek, FillGaps: true, FillTo: time.Date(2020, 5, 20, 0, 0, 0, 0, time.UTC)}
+	require.Nil(t, WriteOutput(&output, GroupByDay(readings), opts))
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	require.Len(t, lines, 5)
+	require.Equal(t, "2020-05-10,2020-05-10,2020-05-16,0,,,,,,,,0,,,,,", lines[3])
+	require.Equal(t, "2020-05-17,2020-05-17,2020-05-23,0,,,,,,,,0,,,,,", lines[4])
+
+	// Charts and reports are of days alone
+	opts = Options{GroupBy: ByMonth, Output: SVGOutput}
+	err := WriteOutput(&output, GroupByDay(readings), opts)
+	require.Equal(t, OutputError, ClassOf(err), "expected an output error for an aggregated chart")
+}
+
+// TestPeriodsToXLSX confirms that the daily sheet of a workbook is replaced by the periods.
+func TestPeriodsToXLSX(t *testing.T) {
+	var output bytes.Buffer
+	require.Nil(t, WriteOutput(&output, GroupByDay(gapReadings()), Options{GroupBy: ByMonth, Output: XLSXOutput}))
+	parts := readWorkbookParts(t, output.Bytes())
+	require.Contains(t, parts["xl/workbook.xml"], `<sheet name="Monthly" sheetId="1" r:id="rId1"/>`)
+	require.Contains(t, parts["xl/worksheets/sheet1.xml"], `2020-05`)
+}
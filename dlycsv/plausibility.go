@@ -0,0 +1,189 @@
+package dlycsv
+
+// The checks that readings are physiologically plausible, and what is done with
+// those that are not.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Bounds is the inclusive range of plausible values for one field of a reading.
+type Bounds struct {
+	Min int // The lowest plausible value
+	Max int // The highest plausible value
+}
+
+// String returns the bounds in the form min-max.
+func (b Bounds) String() string {
+	return fmt.Sprintf("%d-%d", b.Min, b.Max)
+}
+
+// contains returns true if the value is within the bounds.
+func (b Bounds) contains(value int) bool {
+	return value >= b.Min && value <= b.Max
+}
+
+// Limits are the plausible ranges of the pressures and pulse of a reading.
+type Limits struct {
+	Systolic  Bounds // The plausible systolic pressures in mmHg
+	Diastolic Bounds // The plausible diastolic pressures in mmHg
+	Pulse     Bounds // The plausible pulse rates in beats per minute, checked only when recorded
+}
+
+// DefaultLimits are the limits used unless the options give others: wide enough for any
+// reading that a home monitor could genuinely take, but not for typing mistakes.
+var DefaultLimits = Limits{
+	Systolic:  Bounds{Min: 60, Max: 260},
+	Diastolic: Bounds{Min: 30, Max: 160},
+	Pulse:     Bounds{Min: 25, Max: 220},
+}
+
+// String returns the limits in the form that ParseLimits accepts.
+func (l Limits) String() string {
+	return fmt.Sprintf("systolic=%s,diastolic=%s,pulse=%s", l.Systolic, l.Diastolic, l.Pulse)
+}
+
+// ParseLimits parses a comma separated list of field=min-max ranges, such as
+// "systolic=70-250,pulse=30-200", into limits. The fields are systolic, diastolic and
+// pulse; any that are not given keep their DefaultLimits.
+func ParseLimits(spec string) (Limits, error) {
+	limits := DefaultLimits
+	for _, item := range strings.Split(spec, ",") {
+
+		// Split the item into its field and range
+		item = strings.TrimSpace(item)
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			return Limits{}, fmt.Errorf("limit %q is not in the form field=min-max", item)
+		}
+		var bounds *Bounds
+		switch strings.ToLower(strings.TrimSpace(parts[0])) {
+		case "systolic":
+			bounds = &limits.Systolic
+		case "diastolic":
+			bounds = &limits.Diastolic
+		case "pulse":
+			bounds = &limits.Pulse
+		default:
+			return Limits{}, fmt.Errorf("limit %q is not for systolic, diastolic or pulse", item)
+		}
+
+		// Parse the range
+		values := strings.Split(parts[1], "-")
+		if len(values) != 2 {
+			return Limits{}, fmt.Errorf("limit %q is not in the form field=min-max", item)
+		}
+		min, minErr := strconv.Atoi(strings.TrimSpace(values[0]))
+		max, maxErr := strconv.Atoi(strings.TrimSpace(values[1]))
+		if minErr != nil || maxErr != nil || min < 0 || min > max {
+			return Limits{}, fmt.Errorf("limit %q does not have a valid range", item)
+		}
+		*bounds = Bounds{Min: min, Max: max}
+	}
+	return limits, nil
+}
+
+// Plausibility selects what is done with readings that are not physiologically plausible:
+// those with a value outside the limits, or with a diastolic pressure that is not below
+// the systolic. The zero value, FlagImplausible, keeps them, so that nothing is dropped
+// unless it is asked for. Equal pressures cannot be fixed by swapping them.
+type Plausibility int
+
+// The supported plausibility actions.
+const (
+	FlagImplausible   Plausibility = iota // Keep implausible readings, flagged with their problems
+	RejectImplausible                     // Reject implausible readings
+	FixImplausible                        // Swap reversed pressures and clear implausible pulses, rejecting what cannot be fixed
+)
+
+// The names of the plausibility actions, as accepted by ParsePlausibility.
+var plausibilityNames = []string{"flag", "reject", "fix"}
+
+// String returns the name of the plausibility action.
+func (p Plausibility) String() string {
+	if p < 0 || int(p) >= len(plausibilityNames) {
+		return fmt.Sprintf("Plausibility(%d)", int(p))
+	}
+	return plausibilityNames[p]
+}
+
+// ParsePlausibility returns the plausibility action with the given name: one of
+// flag, reject or fix.
+func ParsePlausibility(name string) (Plausibility, error) {
+	for index, plausibilityName := range plausibilityNames {
+		if strings.EqualFold(name, plausibilityName) {
+			return Plausibility(index), nil
+		}
+	}
+	return FlagImplausible, fmt.Errorf("unknown plausibility action %q, expected one of %s",
+		name, strings.Join(plausibilityNames, ", "))
+}
+
+// checkPlausibility checks a reading against the limits of the options and applies the
+// plausibility action of the options to it. It returns the reading to keep, false if the
+// reading is rejected, and a Reject describing any problem found, nil if there was none.
+// The caller fills in the Text of the Reject, if it has the raw text of the record.
+func checkPlausibility(reading Reading, opts Options) (Reading, bool, *Reject) {
+
+	limits := DefaultLimits
+	if opts.Limits != nil {
+		limits = *opts.Limits
+	}
+
+	// Reversed pressures can be fixed by swapping them back, but equal pressures cannot
+	var problems []string
+	outcome := Flagged
+	unfixable := false
+	if reading.Diastolic == reading.Systolic {
+		problems = append(problems, fmt.Sprintf("diastolic %d is not below systolic %d", reading.Diastolic, reading.Systolic))
+		unfixable = true
+	} else if reading.Diastolic > reading.Systolic {
+		problems = append(problems, fmt.Sprintf("diastolic %d is not below systolic %d", reading.Diastolic, reading.Systolic))
+		if opts.Implausible == FixImplausible {
+			reading.Systolic, reading.Diastolic = reading.Diastolic, reading.Systolic
+			problems[0] += ", swapped"
+			outcome = Fixed
+		}
+	}
+
+	// An unrecorded pulse is always plausible, an implausible one can be fixed by forgetting it
+	if reading.Pulse != 0 && !limits.Pulse.contains(reading.Pulse) {
+		problem := fmt.Sprintf("pulse %d is outside %s", reading.Pulse, limits.Pulse)
+		if opts.Implausible == FixImplausible {
+			reading.Pulse = 0
+			problem += ", cleared"
+			outcome = Fixed
+		}
+		problems = append(problems, problem)
+	}
+
+	// Nor can implausible pressures
+	if !limits.Systolic.contains(reading.Systolic) {
+		problems = append(problems, fmt.Sprintf("systolic %d is outside %s", reading.Systolic, limits.Systolic))
+		unfixable = true
+	}
+	if !limits.Diastolic.contains(reading.Diastolic) {
+		problems = append(problems, fmt.Sprintf("diastolic %d is outside %s", reading.Diastolic, limits.Diastolic))
+		unfixable = true
+	}
+
+	// Nothing to do if the reading is plausible
+	if len(problems) == 0 {
+		return reading, true, nil
+	}
+	reject := &Reject{Source: reading.Source, Line: reading.Line, Reason: strings.Join(problems, "; "), Outcome: outcome}
+	switch {
+	case opts.Implausible == RejectImplausible || (opts.Implausible == FixImplausible && unfixable):
+		reject.Outcome = Rejected
+		return Reading{}, false, reject
+	case opts.Implausible == FlagImplausible:
+		reading.Flag = reject.Reason
+	}
+	return reading, true, reject
+}
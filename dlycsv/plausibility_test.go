@@ -0,0 +1,122 @@
+package dlycsv
+
+// Unit tests for the physiological plausibility checks.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The implausible input used by the plausibility tests.
+const implausibleInput = `Date Time,Systolic,Diastolic,Pulse,Note
+May 01 2020 07:00:00,120,80,60,plausible
+May 01 2020 08:00:00,80,120,61,reversed
+May 01 2020 09:00:00,900,80,62,typing mistake
+May 01 2020 10:00:00,125,82,400,racing
+`
+
+// parseImplausible parses the implausible input with the given options, returning the
+// readings and the problems reported.
+func parseImplausible(t *testing.T, opts Options) ([]Reading, []Reject) {
+	var rejects []Reject
+	opts.Source = "test"
+	opts.Rejected = func(reject Reject) { rejects = append(rejects, reject) }
+	readings, err := ParseReadings(context.Background(), strings.NewReader(implausibleInput), opts)
+	require.Nil(t, err, "ParseReadings returned an error: %v", err)
+	return readings, rejects
+}
+
+// TestRejectImplausible confirms that implausible readings are rejected if asked.
+func TestRejectImplausible(t *testing.T) {
+	readings, rejects := parseImplausible(t, Options{Implausible: RejectImplausible})
+	require.Len(t, readings, 1, "only the plausible reading should be kept")
+	require.Equal(t, []Reject{
+		{Source: "test", Line: 3, Text: "May 01 2020 08:00:00,80,120,61,reversed", Reason: "diastolic 120 is not below systolic 80"},
+		{Source: "test", Line: 4, Text: "May 01 2020 09:00:00,900,80,62,typing mistake", Reason: "systolic 900 is outside 60-260"},
+		{Source: "test", Line: 5, Text: "May 01 2020 10:00:00,125,82,400,racing", Reason: "pulse 400 is outside 25-220"},
+	}, rejects)
+}
+
+// TestFlagImplausible confirms that flagged readings are kept with their problems, as
+// they are by default.
+func TestFlagImplausible(t *testing.T) {
+	defaults, _ := parseImplausible(t, Options{})
+	readings, rejects := parseImplausible(t, Options{Implausible: FlagImplausible})
+	require.Equal(t, readings, defaults, "flagging should be the default")
+	require.Len(t, readings, 4, "every reading should be kept")
+	require.Equal(t, "", readings[0].Flag)
+	require.Equal(t, "diastolic 120 is not below systolic 80", readings[1].Flag)
+	require.Len(t, rejects, 3)
+	for _, reject := range rejects {
+		require.Equal(t, Flagged, reject.Outcome)
+	}
+
+	// The flags get a column of their own in the daily output
+	var output bytes.Buffer
+	require.Nil(t, WriteDaily(&output, GroupByDay(readings), Options{Policy: KeepFirst}))
+	require.Equal(t, "Date Time (first),Systolic (first),Diastolic (first),Pulse (first),Note (first),Flag (first)",
+		strings.Split(output.String(), "\n")[0])
+}
+
+// TestFixImplausible confirms that reversed pressures and implausible pulses are fixed,
+// while implausible pressures are still rejected.
+func TestFixImplausible(t *testing.T) {
+	readings, rejects := parseImplausible(t, Options{Implausible: FixImplausible})
+	require.Len(t, readings, 3, "the typing mistake cannot be fixed")
+	require.Equal(t, 120, readings[1].Systolic)
+	require.Equal(t, 80, readings[1].Diastolic)
+	require.Equal(t, 0, readings[2].Pulse, "the implausible pulse should be cleared")
+	require.Equal(t, Fixed, rejects[0].Outcome)
+	require.Equal(t, "diastolic 120 is not below systolic 80, swapped", rejects[0].Reason)
+	require.Equal(t, Rejected, rejects[1].Outcome)
+	require.Equal(t, "pulse 400 is outside 25-220, cleared", rejects[2].Reason)
+}
+
+// TestFixEqualPressures confirms that equal pressures, which swapping cannot fix, are
+// rejected rather than counted as fixed, and are flagged as before when not fixing.
+func TestFixEqualPressures(t *testing.T) {
+	reading := Reading{Systolic: 90, Diastolic: 90, Pulse: 60}
+	_, keep, reject := checkPlausibility(reading, Options{Implausible: FixImplausible})
+	require.False(t, keep, "equal pressures cannot be fixed")
+	require.Equal(t, Rejected, reject.Outcome)
+	require.Equal(t, "diastolic 90 is not below systolic 90", reject.Reason)
+
+	kept, keep, reject := checkPlausibility(reading, Options{})
+	require.True(t, keep, "equal pressures should be kept when flagging")
+	require.Equal(t, Flagged, reject.Outcome)
+	require.Equal(t, reject.Reason, kept.Flag)
+}
+
+// TestLimits confirms that limits can be parsed and are applied.
+func TestLimits(t *testing.T) {
+	limits, err := ParseLimits("systolic=70-1000, pulse=30-500")
+	require.Nil(t, err, "ParseLimits returned an error: %v", err)
+	require.Equal(t, "systolic=70-1000,diastolic=30-160,pulse=30-500", limits.String())
+	readings, _ := parseImplausible(t, Options{Limits: &limits, Implausible: RejectImplausible})
+	require.Len(t, readings, 3, "only the reversed reading should be rejected")
+
+	// Badly formed limits are refused
+	for _, spec := range []string{"systolic", "heart=1-2", "pulse=30", "pulse=200-30", "pulse=a-b"} {
+		_, err := ParseLimits(spec)
+		require.NotNil(t, err, "%q should have been refused", spec)
+	}
+}
+
+// TestParsePlausibility confirms that plausibility actions can be found by name.
+func TestParsePlausibility(t *testing.T) {
+	for _, action := range []Plausibility{RejectImplausible, FlagImplausible, FixImplausible} {
+		parsed, err := ParsePlausibility(strings.ToUpper(action.String()))
+		require.Nil(t, err, "ParsePlausibility returned an error: %v", err)
+		require.Equal(t, action, parsed)
+	}
+	_, err := ParsePlausibility("ignore")
+	require.NotNil(t, err, "an unknown action should have been refused")
+}
@@ -0,0 +1,174 @@
+package dlycsv
+
+// Policies for resolving several readings that fall in the same day or time slot
+// down to a single reading.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Policy selects how several readings in the same day, or the same time slot, are
+// resolved. The zero value, KeepAll, keeps every reading.
+type Policy int
+
+// The supported policies.
+const (
+	KeepAll     Policy = iota // Keep every reading
+	KeepFirst                 // Keep the earliest reading
+	KeepLast                  // Keep the latest reading
+	KeepHighest               // Keep the reading with the highest systolic (then diastolic) pressure
+	KeepLowest                // Keep the reading with the lowest systolic (then diastolic) pressure
+	Average                   // Replace the readings with their mean
+	Median                    // Replace the readings with their median
+)
+
+// The names of the policies, as accepted by ParsePolicy and shown in output headers.
+var policyNames = []string{"all", "first", "last", "highest", "lowest", "average", "median"}
+
+// String returns the name of the policy.
+func (p Policy) String() string {
+	if p < 0 || int(p) >= len(policyNames) {
+		return fmt.Sprintf("Policy(%d)", int(p))
+	}
+	return policyNames[p]
+}
+
+// ParsePolicy returns the policy with the given name: one of all, first, last,
+// highest, lowest, average or median.
+func ParsePolicy(name string) (Policy, error) {
+	for index, policyName := range policyNames {
+		if strings.EqualFold(name, policyName) {
+			return Policy(index), nil
+		}
+	}
+	return KeepAll, fmt.Errorf("unknown policy %q, expected one of %s", name, strings.Join(policyNames, ", "))
+}
+
+// Resolve applies the policy to the given readings, which must be in ascending time order,
+// returning the readings to be kept. Every policy other than KeepAll returns at most one
+// reading. Average and Median produce a new reading whose values are computed field by
+// field, rounded to the nearest whole number, with the notes of all the readings joined
+// together, the most significant of their detections, and the position indicator and
+// source metadata of the earliest reading.
+func (p Policy) Resolve(readings []Reading) []Reading {
+
+	// There is nothing to resolve unless we have more than one reading
+	if len(readings) < 2 || p == KeepAll {
+		return readings
+	}
+
+	switch p {
+	case KeepFirst:
+		return readings[:1]
+	case KeepLast:
+		return readings[len(readings)-1:]
+	case KeepHighest:
+		return []Reading{pickReading(readings, higherThan)}
+	case KeepLowest:
+		return []Reading{pickReading(readings, func(a, b Reading) bool { return higherThan(b, a) })}
+	case Average:
+		return []Reading{combineReadings(readings, meanOf)}
+	case Median:
+		return []Reading{combineReadings(readings, medianOf)}
+	}
+	return readings
+}
+
+// higherThan returns true if reading a has a higher systolic pressure than reading b,
+// or the same systolic and a higher diastolic pressure.
+func higherThan(a, b Reading) bool {
+	if a.Systolic != b.Systolic {
+		return a.Systolic > b.Systolic
+	}
+	return a.Diastolic > b.Diastolic
+}
+
+// pickReading returns the first of the readings that no later reading beats.
+func pickReading(readings []Reading, beats func(a, b Reading) bool) Reading {
+	picked := readings[0]
+	for _, reading := range readings[1:] {
+		if beats(reading, picked) {
+			picked = reading
+		}
+	}
+	return picked
+}
+
+// combineReadings builds a single reading from several by applying the given statistic
+// to each of the numeric fields and to the reading times. The pulse is combined from the
+// readings that recorded one, and left unrecorded if none of them did.
+func combineReadings(readings []Reading, statistic func([]float64) float64) Reading {
+
+	// Take the metadata from the first reading
+	combined := readings[0]
+
+	// Gather the values of each field, noting the most significant detections
+	times := make([]float64, len(readings))
+	systolic := make([]float64, len(readings))
+	diastolic := make([]float64, len(readings))
+	var pulse []float64
+	var notes []string
+	for index, reading := range readings {
+		times[index] = float64(reading.Time.Sub(readings[0].Time))
+		systolic[index] = float64(reading.Systolic)
+		diastolic[index] = float64(reading.Diastolic)
+		if reading.Pulse != 0 {
+			pulse = append(pulse, float64(reading.Pulse))
+		}
+		if reading.Note != "" {
+			notes = append(notes, reading.Note)
+		}
+		if reading.IrregularHeartbeat > combined.IrregularHeartbeat {
+			combined.IrregularHeartbeat = reading.IrregularHeartbeat
+		}
+		if reading.BodyMovement > combined.BodyMovement {
+			combined.BodyMovement = reading.BodyMovement
+		}
+	}
+
+	// Fill in the combined values
+	combined.Time = readings[0].Time.Add(time.Duration(statistic(times))).Truncate(time.Second)
+	combined.Systolic = roundToInt(statistic(systolic))
+	combined.Diastolic = roundToInt(statistic(diastolic))
+	combined.Pulse = 0
+	if len(pulse) > 0 {
+		combined.Pulse = roundToInt(statistic(pulse))
+	}
+	combined.Note = strings.Join(notes, "; ")
+	return combined
+}
+
+// meanOf returns the arithmetic mean of the given values.
+func meanOf(values []float64) float64 {
+	total := 0.0
+	for _, value := range values {
+		total += value
+	}
+	return total / float64(len(values))
+}
+
+// medianOf returns the median of the given values, the mean of the middle two if
+// there is an even number of them.
+func medianOf(values []float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+// roundToInt rounds the given value to the nearest whole number, halves away from zero.
+func roundToInt(value float64) int {
+	return int(math.Round(value))
+}
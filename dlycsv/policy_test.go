@@ -0,0 +1,123 @@
+package dlycsv
+
+// Unit tests for the duplicate resolution policies.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// policyTestReadings returns three readings from the same evening, in time order.
+func policyTestReadings() []Reading {
+	evening := time.Date(2020, 4, 29, 18, 0, 0, 0, time.UTC)
+	return []Reading{
+		{Time: evening, Systolic: 101, Diastolic: 77, Pulse: 63, Note: "one"},
+		{Time: evening.Add(time.Hour), Systolic: 120, Diastolic: 70, Pulse: 70},
+		{Time: evening.Add(3 * time.Hour), Systolic: 98, Diastolic: 79, Pulse: 67, Note: "three"},
+	}
+}
+
+// TestParsePolicy confirms that policy names are parsed and reported.
+func TestParsePolicy(t *testing.T) {
+	for index, name := range policyNames {
+		policy, err := ParsePolicy(strings.ToUpper(name))
+		require.Nil(t, err, "ParsePolicy returned an error: %v", err)
+		require.Equal(t, Policy(index), policy, "wrong policy for %s", name)
+		require.Equal(t, name, policy.String(), "wrong name for policy")
+	}
+	_, err := ParsePolicy("loudest")
+	require.NotNil(t, err, "expected an unknown policy to be rejected")
+	require.Equal(t, "Policy(99)", Policy(99).String())
+}
+
+// TestResolve confirms the reading that each policy keeps or produces.
+func TestResolve(t *testing.T) {
+	readings := policyTestReadings()
+
+	require.Equal(t, readings, KeepAll.Resolve(readings), "all readings should be kept")
+	require.Equal(t, readings[:1], KeepFirst.Resolve(readings), "the first reading should be kept")
+	require.Equal(t, readings[2:], KeepLast.Resolve(readings), "the last reading should be kept")
+	require.Equal(t, readings[1:2], KeepHighest.Resolve(readings), "the highest reading should be kept")
+	require.Equal(t, readings[2:], KeepLowest.Resolve(readings), "the lowest reading should be kept")
+
+	// The average is computed field by field, and rounded
+	average := Average.Resolve(readings)
+	require.Equal(t, 1, len(average), "average should produce one reading")
+	require.Equal(t, "19:20:00", average[0].Time.Format("15:04:05"), "wrong average time")
+	require.Equal(t, 106, average[0].Systolic, "wrong average systolic")
+	require.Equal(t, 75, average[0].Diastolic, "wrong average diastolic")
+	require.Equal(t, 67, average[0].Pulse, "wrong average pulse")
+	require.Equal(t, "one; three", average[0].Note, "notes should be joined")
+
+	// The median is also computed field by field
+	median := Median.Resolve(readings)
+	require.Equal(t, 1, len(median), "median should produce one reading")
+	require.Equal(t, "19:00:00", median[0].Time.Format("15:04:05"), "wrong median time")
+	require.Equal(t, 101, median[0].Systolic, "wrong median systolic")
+	require.Equal(t, 77, median[0].Diastolic, "wrong median diastolic")
+	require.Equal(t, 67, median[0].Pulse, "wrong median pulse")
+	require.Equal(t, 109, Median.Resolve(readings[1:])[0].Systolic, "even counts should use the middle two")
+
+	// A single reading is always kept as it is
+	require.Equal(t, readings[:1], Average.Resolve(readings[:1]), "a single reading should be kept")
+}
+
+// TestResolveMissingPulse confirms that the average and median pulse are taken only from
+// the readings that recorded one, and left unrecorded if none of them did.
+func TestResolveMissingPulse(t *testing.T) {
+	readings := policyTestReadings()
+	readings[0].Pulse = 0
+	readings[2].Pulse = 0
+
+	// A single pulse stands for all three readings
+	require.Equal(t, 70, Average.Resolve(readings)[0].Pulse, "wrong average pulse")
+	require.Equal(t, 70, Median.Resolve(readings)[0].Pulse, "wrong median pulse")
+
+	// Two pulses are combined between themselves
+	readings[2].Pulse = 66
+	require.Equal(t, 68, Average.Resolve(readings)[0].Pulse, "wrong average pulse")
+	require.Equal(t, 68, Median.Resolve(readings)[0].Pulse, "wrong median pulse")
+
+	// And no pulses leave the pulse unrecorded
+	readings[1].Pulse = 0
+	readings[2].Pulse = 0
+	require.Equal(t, 0, Average.Resolve(readings)[0].Pulse, "the average pulse should be unrecorded")
+	require.Equal(t, 0, Median.Resolve(readings)[0].Pulse, "the median pulse should be unrecorded")
+	require.Equal(t, 106, Average.Resolve(readings)[0].Systolic, "the pressures should still be averaged")
+}
+
+// TestConvertWithPolicy confirms that the policy is applied to each day, or each slot,
+// and recorded in the header.
+func TestConvertWithPolicy(t *testing.T) {
+
+	input := "Date Time,Systolic,Diastolic,Pulse,Note\n" +
+		"Apr 29 2020 21:30:15,98,79,67,Third\n" +
+		"Apr 29 2020 06:58:02,94,66,50,First\n" +
+		"Apr 29 2020 18:42:54,101,77,63,Second\n"
+
+	// Without slots, each day is reduced to one reading
+	var output bytes.Buffer
+	err := Convert(context.Background(), strings.NewReader(input), &output, Options{Policy: KeepLast})
+	require.Nil(t, err, "Convert returned an error: %v", err)
+	require.Equal(t, "Date Time (last),Systolic (last),Diastolic (last),Pulse (last),Note (last)\n"+
+		"2020-04-29 21:30:15,98,79,67,Third\n", output.String())
+
+	// With slots, each slot is reduced to one reading
+	slots, _ := ParseSlots("Morning=04:00-12:00,Evening=18:00-04:00")
+	output.Reset()
+	err = Convert(context.Background(), strings.NewReader(input), &output, Options{Slots: slots, Policy: KeepHighest})
+	require.Nil(t, err, "Convert returned an error: %v", err)
+	require.Equal(t, "Morning Date Time (highest),Morning Systolic (highest),Morning Diastolic (highest),"+
+		"Morning Pulse (highest),Morning Note (highest),Evening Date Time (highest),Evening Systolic (highest),"+
+		"Evening Diastolic (highest),Evening Pulse (highest),Evening Note (highest)\n"+
+		"2020-04-29 06:58:02,94,66,50,First,2020-04-29 18:42:54,101,77,63,Second\n", output.String())
+}
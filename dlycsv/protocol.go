@@ -0,0 +1,184 @@
+package dlycsv
+
+// The evaluation of home blood pressure monitoring protocols, such as the 7-day schedule
+// of the European Society of Hypertension, that clinicians ask patients to follow before
+// a diagnosis or a change of treatment.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Protocol describes a home blood pressure monitoring schedule: a number of consecutive
+// days, each with the same measurement sessions, with the mean of the readings compared
+// to the home hypertension threshold.
+type Protocol struct {
+	Name            string        // The name of the protocol, for reports
+	Days            int           // The number of days that the protocol lasts
+	Sessions        []Slot        // The measurement sessions of each day, such as morning and evening
+	DayStart        time.Duration // The time of day at which each day starts, so that late evening readings join their day
+	SessionReadings int           // The number of readings to be taken in each session
+	DiscardFirstDay bool          // True if the readings of the first day are left out of the mean
+	MinimumReadings int           // The fewest readings that must be counted for the mean to be valid
+	Systolic        int           // The home systolic pressure at or above which the mean is raised
+	Diastolic       int           // The home diastolic pressure at or above which the mean is raised
+}
+
+// ESHHomeProtocol is the 7-day home monitoring protocol of the European Society of
+// Hypertension: two readings each morning and evening, the first day left out, at least
+// 12 readings counted, and a home hypertension threshold of 135/85 mmHg. Days start at
+// 04:00 so that readings taken after midnight count towards the evening before.
+var ESHHomeProtocol = Protocol{
+	Name: "ESH home monitoring",
+	Days: 7,
+	Sessions: []Slot{
+		{Name: "Morning", Start: 4 * time.Hour, End: 12 * time.Hour},
+		{Name: "Evening", Start: 16 * time.Hour, End: 4 * time.Hour},
+	},
+	DayStart:        4 * time.Hour,
+	SessionReadings: 2,
+	DiscardFirstDay: true,
+	MinimumReadings: 12,
+	Systolic:        135,
+	Diastolic:       85,
+}
+
+// ProtocolSession is one measurement session of one day of a protocol.
+type ProtocolSession struct {
+	Date     time.Time // Midnight at the start of the day of the session
+	Name     string    // The name of the session
+	Readings []Reading // The readings counted for the session, no more than the protocol asks for
+}
+
+// ProtocolDay is one day of a protocol.
+type ProtocolDay struct {
+	Date      time.Time         // Midnight at the start of the day
+	Sessions  []ProtocolSession // The sessions of the day, in the order of the protocol
+	Discarded bool              // True if the readings of the day are left out of the mean
+}
+
+// ProtocolReport is the outcome of a protocol: its days and sessions, the mean of the
+// readings that count towards it, and how well the schedule was kept.
+type ProtocolReport struct {
+	Protocol Protocol          // The protocol that was followed
+	From     time.Time         // Midnight at the start of the first day of the protocol
+	To       time.Time         // Midnight at the start of the last day of the protocol
+	Days     []ProtocolDay     // Every day of the protocol, in date order
+	Missed   []ProtocolSession // The sessions, after any discarded first day, with fewer readings than the protocol asks for
+	Stats    DailyStats        // The statistics of the readings that count towards the mean
+	Expected int               // The number of readings that the protocol asks to be counted
+
+	FirstDayReadings int // The readings of the first day, left out of the mean
+	ExtraReadings    int // The readings beyond those that a session asks for, left out of the mean
+	OutsideReadings  int // The readings of the protocol's days that fall outside every session
+}
+
+// Valid returns true if enough readings were counted for the mean to be used.
+func (r *ProtocolReport) Valid() bool {
+	return r.Stats.Count >= r.Protocol.MinimumReadings
+}
+
+// Raised returns true if the mean pressures reach the home hypertension threshold.
+func (r *ProtocolReport) Raised() bool {
+	return r.Stats.Count > 0 && (r.Stats.Systolic.Mean >= float64(r.Protocol.Systolic) ||
+		r.Stats.Diastolic.Mean >= float64(r.Protocol.Diastolic))
+}
+
+// EvaluateProtocol measures the given readings against the protocol. The days of the
+// protocol start on the From day of the options or, if it is not given, end on the To day
+// or, failing that, on the last day with readings. If the options have Slots, they are the
+// sessions of each day in place of those of the protocol, and a DayStart of the options
+// takes the place of that of the protocol.
+func EvaluateProtocol(readings []Reading, protocol Protocol, opts Options) *ProtocolReport {
+	if len(opts.Slots) > 0 {
+		protocol.Sessions = opts.Slots
+	}
+	if opts.DayStart != 0 {
+		protocol.DayStart = opts.DayStart
+	}
+	report := &ProtocolReport{Protocol: protocol}
+	groups := GroupByDayStartingAt(readings, protocol.DayStart)
+
+	// Work out the days of the protocol
+	switch {
+	case !opts.From.IsZero():
+		report.From = dateIn(opts.From, time.UTC)
+	case !opts.To.IsZero():
+		report.From = dateIn(opts.To, time.UTC).AddDate(0, 0, 1-protocol.Days)
+	case len(groups) > 0:
+		report.From = dateIn(groups[len(groups)-1].Date, time.UTC).AddDate(0, 0, 1-protocol.Days)
+	default:
+		report.From = dateIn(time.Now(), time.UTC).AddDate(0, 0, 1-protocol.Days)
+	}
+	if len(groups) > 0 {
+		report.From = dateIn(report.From, groups[0].Date.Location())
+	}
+	report.To = startOfDay(report.From.AddDate(0, 0, protocol.Days-1))
+
+	// Take the readings of each session of each day, up to the number asked for
+	var counted []Reading
+	for offset := 0; offset < protocol.Days; offset++ {
+		group := DailyGroup{Date: startOfDay(report.From.AddDate(0, 0, offset))}
+		for _, candidate := range groups {
+			if daysBetween(candidate.Date, group.Date) == 0 {
+				group = candidate
+			}
+		}
+		day := ProtocolDay{Date: group.Date, Discarded: protocol.DiscardFirstDay && offset == 0}
+		inSessions := 0
+		for index, readings := range group.BySlot(protocol.Sessions) {
+			inSessions += len(readings)
+			if len(readings) > protocol.SessionReadings {
+				if !day.Discarded {
+					report.ExtraReadings += len(readings) - protocol.SessionReadings
+				}
+				readings = readings[:protocol.SessionReadings]
+			}
+			session := ProtocolSession{Date: group.Date, Name: protocol.Sessions[index].Name, Readings: readings}
+			day.Sessions = append(day.Sessions, session)
+			if day.Discarded {
+				report.FirstDayReadings += len(readings)
+				continue
+			}
+			if len(readings) < protocol.SessionReadings {
+				report.Missed = append(report.Missed, session)
+			}
+			counted = append(counted, readings...)
+		}
+		report.OutsideReadings += len(group.Readings) - inSessions
+		report.Days = append(report.Days, day)
+	}
+
+	// Every day but a discarded first one counts towards the mean
+	countedDays := protocol.Days
+	if protocol.DiscardFirstDay {
+		countedDays--
+	}
+	report.Expected = countedDays * len(protocol.Sessions) * protocol.SessionReadings
+	report.Stats = ComputeStats(counted)
+	return report
+}
+
+// EvaluateProtocolFiles reads and merges the readings of the given inputs, in the same way
+// as SummarizeFiles, and measures them against the protocol with EvaluateProtocol. The From
+// and To days of the options are those of the protocol's days. Rejected records are passed
+// to the Rejected handler of the options.
+func EvaluateProtocolFiles(inputPaths []string, protocol Protocol, opts Options) (*ProtocolReport, error) {
+	if protocol.Days < 1 || len(protocol.Sessions) == 0 && len(opts.Slots) == 0 || protocol.SessionReadings < 1 {
+		return nil, fmt.Errorf("protocol %q must have at least one day, one session and one reading per session", protocol.Name)
+	}
+	if opts.DayStart == 0 {
+		opts.DayStart = protocol.DayStart
+	}
+	readings, rejects, _, err := parseFiles(context.Background(), inputPaths, opts)
+	if err != nil {
+		return nil, err
+	}
+	reportRejects(rejects, opts)
+	return EvaluateProtocol(readings, protocol, opts), nil
+}
@@ -0,0 +1,142 @@
+package dlycsv
+
+// Unit tests for the evaluation of home monitoring protocols.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// protocolReadings returns the readings of a complete ESH protocol from the 1st to the 7th
+// of June 2020, with a morning session of 130/80 and an evening session of 140/90 each day.
+// The evening readings of the last day are taken just after midnight.
+func protocolReadings() []Reading {
+	var readings []Reading
+	for day := 1; day <= 7; day++ {
+		evening := time.Date(2020, 6, day, 21, 0, 0, 0, time.UTC)
+		if day == 7 {
+			evening = time.Date(2020, 6, 8, 0, 30, 0, 0, time.UTC)
+		}
+		readings = append(readings,
+			Reading{Time: time.Date(2020, 6, day, 7, 0, 0, 0, time.UTC), Systolic: 130, Diastolic: 80, Pulse: 60},
+			Reading{Time: time.Date(2020, 6, day, 7, 2, 0, 0, time.UTC), Systolic: 130, Diastolic: 80, Pulse: 60},
+			Reading{Time: evening, Systolic: 140, Diastolic: 90, Pulse: 64},
+			Reading{Time: evening.Add(2 * time.Minute), Systolic: 140, Diastolic: 90, Pulse: 64})
+	}
+	return readings
+}
+
+// TestEvaluateProtocol confirms that a complete protocol leaves out the first day and
+// takes the mean of the rest.
+func TestEvaluateProtocol(t *testing.T) {
+
+	// The days end on the last day with readings, which the readings after midnight join
+	readings := protocolReadings()
+	readings[0].Systolic = 200
+	report := EvaluateProtocol(readings, ESHHomeProtocol, Options{})
+	require.Equal(t, "2020-06-01", report.From.Format("2006-01-02"))
+	require.Equal(t, "2020-06-07", report.To.Format("2006-01-02"))
+	require.Len(t, report.Days, 7)
+	require.True(t, report.Days[0].Discarded)
+	require.False(t, report.Days[1].Discarded)
+	require.Len(t, report.Days[6].Sessions[1].Readings, 2)
+
+	// The first day is left out of the mean
+	require.Equal(t, 24, report.Expected)
+	require.Equal(t, 24, report.Stats.Count)
+	require.Equal(t, 4, report.FirstDayReadings)
+	require.Equal(t, 135.0, report.Stats.Systolic.Mean)
+	require.Equal(t, 85.0, report.Stats.Diastolic.Mean)
+	require.Empty(t, report.Missed)
+	require.True(t, report.Valid())
+	require.True(t, report.Raised())
+
+	// Lower pressures are below the threshold
+	for index := range readings {
+		readings[index].Systolic -= 10
+		readings[index].Diastolic -= 10
+	}
+	report = EvaluateProtocol(readings, ESHHomeProtocol, Options{})
+	require.True(t, report.Valid())
+	require.False(t, report.Raised())
+}
+
+// TestProtocolShortfalls confirms that missed sessions, extra readings and readings outside
+// the sessions are reported, and that too few readings make the mean invalid.
+func TestProtocolShortfalls(t *testing.T) {
+
+	// Drop both evening readings of the 3rd, one of the 4th and repeat a morning reading
+	readings := protocolReadings()
+	readings = append(readings[:10], readings[12:]...)
+	readings = append(readings[:13], readings[14:]...)
+	readings = append(readings,
+		Reading{Time: time.Date(2020, 6, 5, 7, 4, 0, 0, time.UTC), Systolic: 180, Diastolic: 100},
+		Reading{Time: time.Date(2020, 6, 5, 13, 0, 0, 0, time.UTC), Systolic: 180, Diastolic: 100})
+	report := EvaluateProtocol(readings, ESHHomeProtocol, Options{})
+	require.Equal(t, 21, report.Stats.Count)
+	require.Equal(t, 1, report.ExtraReadings)
+	require.Equal(t, 1, report.OutsideReadings)
+	require.Len(t, report.Missed, 2)
+	require.Equal(t, "2020-06-03", report.Missed[0].Date.Format("2006-01-02"))
+	require.Equal(t, "Evening", report.Missed[0].Name)
+	require.Empty(t, report.Missed[0].Readings)
+	require.Len(t, report.Missed[1].Readings, 1)
+	require.True(t, report.Valid())
+
+	// A window that only catches the end of the readings has too few of them
+	opts := Options{From: time.Date(2020, 6, 6, 0, 0, 0, 0, time.UTC)}
+	report = EvaluateProtocol(readings, ESHHomeProtocol, opts)
+	require.Equal(t, "2020-06-12", report.To.Format("2006-01-02"))
+	require.Equal(t, 4, report.Stats.Count)
+	require.Len(t, report.Missed, 10)
+	require.False(t, report.Valid())
+
+	// As does a window that ends before the readings begin
+	opts = Options{To: time.Date(2020, 5, 31, 0, 0, 0, 0, time.UTC)}
+	report = EvaluateProtocol(readings, ESHHomeProtocol, opts)
+	require.Equal(t, "2020-05-25", report.From.Format("2006-01-02"))
+	require.Len(t, report.Days, 7)
+	require.Zero(t, report.Stats.Count)
+	require.False(t, report.Valid())
+	require.False(t, report.Raised())
+}
+
+// TestProtocolSessions confirms that the slots of the options replace the sessions of the
+// protocol.
+func TestProtocolSessions(t *testing.T) {
+	slots, err := ParseSlots("AM=06:00-10:00")
+	require.Nil(t, err)
+	report := EvaluateProtocol(protocolReadings(), ESHHomeProtocol, Options{Slots: slots})
+	require.Equal(t, 12, report.Expected)
+	require.Equal(t, 12, report.Stats.Count)
+	require.Equal(t, 14, report.OutsideReadings)
+	require.Equal(t, 130.0, report.Stats.Systolic.Mean)
+}
+
+// TestEvaluateProtocolFiles confirms that protocols are evaluated from input files, and
+// that a protocol without days is refused.
+func TestEvaluateProtocolFiles(t *testing.T) {
+	report, err := EvaluateProtocolFiles([]string{"../testdata/protocol.in.csv"}, ESHHomeProtocol, Options{})
+	require.Nil(t, err, "the protocol file should have been read: %v", err)
+	require.Equal(t, 23, report.Stats.Count)
+	require.Len(t, report.Missed, 1)
+	require.Equal(t, "2020-06-04", report.Missed[0].Date.Format("2006-01-02"))
+
+	// The readings after midnight on the last day are kept
+	opts := Options{To: time.Date(2020, 6, 6, 0, 0, 0, 0, time.UTC)}
+	report, err = EvaluateProtocolFiles([]string{"../testdata/protocol.in.csv"}, ESHHomeProtocol, opts)
+	require.Nil(t, err, "the protocol file should have been read: %v", err)
+	require.Equal(t, "2020-05-31", report.From.Format("2006-01-02"))
+	require.Len(t, report.Days[6].Sessions[1].Readings, 2)
+
+	protocol := ESHHomeProtocol
+	protocol.Days = 0
+	_, err = EvaluateProtocolFiles([]string{"../testdata/protocol.in.csv"}, protocol, Options{})
+	require.NotNil(t, err, "a protocol without days should be refused")
+}
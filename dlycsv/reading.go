@@ -0,0 +1,235 @@
+package dlycsv
+
+// The typed model of blood pressure readings, and the functions that parse
+// readings from CSV data and group them by day.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"io"
+	"sort"
+	"time"
+)
+
+// The number of bytes at the start of an input that are examined to detect documents.
+const sniffLength = 512
+
+// Reading is a single blood pressure reading.
+type Reading struct {
+	Time      time.Time // When the reading was taken
+	Systolic  int       // The systolic pressure in mmHg
+	Diastolic int       // The diastolic pressure in mmHg
+	Pulse     int       // The pulse rate in beats per minute, zero if not recorded
+	Note      string    // Any note recorded with the reading
+
+	IrregularHeartbeat Detection // Whether the monitor detected an irregular heartbeat
+	BodyMovement       Detection // Whether the monitor detected body movement during the reading
+	Position           string    // The measurement position indicator, as recorded by the monitor
+	Flag               string    // Why the reading is not plausible, if it was kept and flagged
+
+	Source string // The name of the input that the reading came from
+	Line   int    // The line of the input on which the reading was found, counting from 1
+	Text   string // The raw text of the record that the reading was parsed from, if there was one
+}
+
+// Detection records whether a monitor detected a condition, such as an irregular heartbeat,
+// while taking a reading. The values are ordered so that the greater of two detections is
+// the more significant.
+type Detection int
+
+// The possible detection values.
+const (
+	NotRecorded Detection = iota // The monitor did not record the condition
+	NotDetected                  // The monitor looked for the condition and did not find it
+	Detected                     // The monitor detected the condition
+)
+
+// String returns the text used for the detection in the daily output.
+func (d Detection) String() string {
+	switch d {
+	case NotDetected:
+		return "No"
+	case Detected:
+		return "Yes"
+	}
+	return ""
+}
+
+// DailyGroup gathers the readings taken on a single day.
+type DailyGroup struct {
+	Date     time.Time // Midnight at the start of the day, in the time zone of its first reading
+	Readings []Reading // The readings taken on the day, in ascending time order
+}
+
+// ParseReadings reads blood pressure CSV data from the given reader and returns the
+// readings that it contains, in the order that they were found. Records that are not valid
+// readings are skipped and passed to the Rejected handler of the options, if it has one. A
+// record that cannot be read as CSV at all, such as one with the wrong number of fields,
+// fails the whole input unless the options ask for Lenient parsing, in which case it is
+// rejected in the same way. Readings that are not physiologically plausible are rejected,
+// flagged or fixed as the Implausible action of the options says, with their problems also
+// passed to the Rejected handler. Times read without a zone offset are taken to be in the
+// InputZone of the options, and every time is moved into the DisplayZone, if there is one.
+// Readings taken outside the From and To days of the options are left out. The input is
+// parsed as the format given by the options, or as whichever registered format recognizes
+// its header record if the options do not give one. The source name given by the options is
+// recorded in each reading.
+//
+// Reading stops early, returning the context error, if the context is cancelled.
+func ParseReadings(ctx context.Context, r io.Reader, opts Options) ([]Reading, error) {
+	readings, rejects, err := parseReadings(ctx, r, opts)
+	reportRejects(rejects, opts)
+	return readings, err
+}
+
+// parseReadings does the work of ParseReadings, returning the records that were rejected
+// because they were not valid readings rather than reporting them.
+func parseReadings(ctx context.Context, r io.Reader, opts Options) ([]Reading, []Reject, error) {
+
+	// Buffer the input, stopping if we are cancelled, so that we can take a look at the
+	// start of it to see if it is a document rather than a CSV file
+	buffered := bufio.NewReader(&contextReader{ctx: ctx, r: r})
+	prefix, _ := buffered.Peek(sniffLength)
+	if document := detectDocumentFormat(opts.Format, prefix); document != nil {
+		return parseDocument(ctx, document, buffered, opts)
+	}
+
+	// Obtain a record reader on the input
+	reader := newRecordReader(buffered)
+
+	// Check that we have been given blood pressure CSV data, reporting a
+	// cancellation in preference to the failure that it caused
+	parse, err := checkForHeaderRecord(reader, opts.Format)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, nil, ctxErr
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	// Parse each of the records that follow the header
+	var readings []Reading
+	var rejects []Reject
+	for {
+		record, err := reader.Read()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, nil, ctxErr
+		} else if err == io.EOF {
+			break
+		} else if parseErr, ok := err.(*csv.ParseError); ok && opts.Lenient {
+			rejects = append(rejects, Reject{Source: opts.Source, Line: record.Line, Text: record.Text, Reason: parseErr.Err.Error()})
+			continue
+		} else if err != nil {
+			return nil, nil, classifiedErrorf(FormatError, "failed to read body of input file: %w", err)
+		}
+
+		// Keep the record if it is a valid reading, otherwise reject it
+		reading, err := parse(record.Fields)
+		if err != nil {
+			rejects = append(rejects, Reject{Source: opts.Source, Line: record.Line, Text: record.Text, Reason: err.Error()})
+			continue
+		}
+		reading.Source = opts.Source
+		reading.Line = record.Line
+		reading.Text = record.Text
+		reading.Time = opts.localize(reading.Time)
+		if !opts.inDateRange(reading.Time) {
+			continue
+		}
+
+		// Keep the reading if it is, or has been made, plausible
+		reading, keep, reject := checkPlausibility(reading, opts)
+		if reject != nil {
+			reject.Text = record.Text
+			rejects = append(rejects, *reject)
+		}
+		if keep {
+			readings = append(readings, reading)
+		}
+	}
+
+	// We have all that there is to have
+	return readings, rejects, nil
+}
+
+// parseDocument has the given document format read the readings from the input.
+func parseDocument(ctx context.Context, document DocumentFormat, r io.Reader, opts Options) ([]Reading, []Reject, error) {
+	readings, rejects, err := document.ParseDocument(ctx, r)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, nil, ctxErr
+	} else if err != nil {
+		return nil, nil, err
+	}
+	for index := range rejects {
+		rejects[index].Source = opts.Source
+	}
+
+	// Keep the readings in the date range that are, or have been made, plausible
+	var kept []Reading
+	for _, reading := range readings {
+		reading.Source = opts.Source
+		reading.Time = opts.localize(reading.Time)
+		if !opts.inDateRange(reading.Time) {
+			continue
+		}
+		reading, keep, reject := checkPlausibility(reading, opts)
+		if reject != nil {
+			rejects = append(rejects, *reject)
+		}
+		if keep {
+			kept = append(kept, reading)
+		}
+	}
+	return kept, rejects, nil
+}
+
+// GroupByDay sorts the given readings into ascending time order and gathers them into
+// one group for each day on which readings were taken, in ascending date order. Each
+// reading belongs to the calendar date of its own time zone, so readings in different
+// zones that fall on the same date share a group even if their days began at different
+// instants.
+func GroupByDay(readings []Reading) []DailyGroup {
+	return GroupByDayStartingAt(readings, 0)
+}
+
+// GroupByDayStartingAt does the same as GroupByDay with each day starting at the given
+// time of day, as parsed by ParseDayStart, rather than at midnight. Readings taken before
+// the day start belong to the day before, so that a reading taken at 00:30 joins those
+// of the evening before it if the day starts at 04:00. Each group is still dated with the
+// calendar date on which its day starts.
+func GroupByDayStartingAt(readings []Reading, dayStart time.Duration) []DailyGroup {
+
+	// Sort a copy of the readings, leaving the caller's slice alone
+	sorted := make([]Reading, len(readings))
+	copy(sorted, readings)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+
+	// Gather the readings by date, starting a new group for each date we have not seen
+	var groups []DailyGroup
+	byDate := make(map[string]int)
+	for _, reading := range sorted {
+		date := dayOf(reading.Time, dayStart)
+		key := date.Format(dateLayout)
+		index, ok := byDate[key]
+		if !ok {
+			index = len(groups)
+			byDate[key] = index
+			groups = append(groups, DailyGroup{Date: date})
+		}
+		groups[index].Readings = append(groups[index].Readings, reading)
+	}
+
+	// Readings in different zones can reach their dates out of order
+	sort.SliceStable(groups, func(i, j int) bool { return daysBetween(groups[i].Date, groups[j].Date) > 0 })
+	return groups
+}
+
+// startOfDay returns midnight at the start of the day of the given time.
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,84 @@
+package dlycsv
+
+// Unit tests for the typed reading model.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseReadings confirms that readings are parsed into typed values and carry
+// their source metadata.
+func TestParseReadings(t *testing.T) {
+
+	// Parse the happy path input
+	inputFile, err := os.Open("../testdata/happypath.in.csv")
+	require.Nil(t, err, "could not open input file: %v", err)
+	defer inputFile.Close()
+	readings, err := ParseReadings(context.Background(), inputFile, Options{Source: "happypath"})
+	require.Nil(t, err, "ParseReadings returned an error: %v", err)
+
+	// The invalid lines should have been skipped
+	require.Equal(t, 22, len(readings), "wrong number of readings")
+
+	// Check the first reading in full
+	first := readings[0]
+	require.Equal(t, "2020-05-28 20:59:29", first.Time.Format("2006-01-02 15:04:05"))
+	require.Equal(t, 92, first.Systolic, "wrong systolic value")
+	require.Equal(t, 66, first.Diastolic, "wrong diastolic value")
+	require.Equal(t, 52, first.Pulse, "wrong pulse value")
+	require.Equal(t, "", first.Note, "wrong note")
+	require.Equal(t, "happypath", first.Source, "wrong source")
+	require.Equal(t, 2, first.Line, "wrong line number")
+
+	// Line numbers should account for the blank and invalid lines
+	require.Equal(t, "Second reading", readings[4].Note, "wrong note")
+	require.Equal(t, 6, readings[4].Line, "wrong line number")
+	require.Equal(t, 12, readings[8].Line, "wrong line number after blank line")
+}
+
+// TestParseReadingsSkipsNonNumeric confirms that records with values that are not
+// numbers are not readings.
+func TestParseReadingsSkipsNonNumeric(t *testing.T) {
+
+	input := "Date Time,Systolic,Diastolic,Pulse,Note\n" +
+		"May 28 2020 20:59:29,abc,66,52,\n" +
+		"May 28 2020 06:18:27,92,68,57,\n"
+	readings, err := ParseReadings(context.Background(), strings.NewReader(input), Options{})
+	require.Nil(t, err, "ParseReadings returned an error: %v", err)
+	require.Equal(t, 1, len(readings), "the non-numeric record should have been skipped")
+	require.Equal(t, 3, readings[0].Line, "wrong line number")
+}
+
+// TestGroupByDay confirms that readings are sorted and grouped by date.
+func TestGroupByDay(t *testing.T) {
+
+	input := "Date Time,Systolic,Diastolic,Pulse,Note\n" +
+		"Apr 29 2020 21:30:15,98,79,67,Third\n" +
+		"Apr 28 2020 06:06:13,92,67,57,\n" +
+		"Apr 29 2020 06:58:02,94,66,50,First\n" +
+		"Apr 29 2020 18:42:54,101,77,63,Second\n"
+	readings, err := ParseReadings(context.Background(), strings.NewReader(input), Options{})
+	require.Nil(t, err, "ParseReadings returned an error: %v", err)
+
+	// There should be two days, in ascending order, with the readings sorted
+	groups := GroupByDay(readings)
+	require.Equal(t, 2, len(groups), "wrong number of days")
+	require.Equal(t, "2020-04-28 00:00:00", groups[0].Date.Format("2006-01-02 15:04:05"))
+	require.Equal(t, 1, len(groups[0].Readings), "wrong number of readings on the first day")
+	require.Equal(t, "2020-04-29 00:00:00", groups[1].Date.Format("2006-01-02 15:04:05"))
+	require.Equal(t, 3, len(groups[1].Readings), "wrong number of readings on the second day")
+	require.Equal(t, "First", groups[1].Readings[0].Note, "readings should be in time order")
+	require.Equal(t, "Third", groups[1].Readings[2].Note, "readings should be in time order")
+
+	// The caller's readings should not have been reordered
+	require.Equal(t, "Third", readings[0].Note, "input slice should not have been sorted")
+}
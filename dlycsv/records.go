@@ -0,0 +1,122 @@
+package dlycsv
+
+// A CSV record reader that remembers where each record came from.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// rawRecord is a single CSV record together with the line on which it started
+// and the text from which it was parsed.
+type rawRecord struct {
+	Line   int      // The line number, counting from 1, on which the record started
+	Text   string   // The raw text of the record, without its line terminator
+	Fields []string // The fields parsed from the record
+}
+
+// recordReader reads CSV records one at a time in the same way as csv.Reader, skipping
+// blank lines and requiring every record to have the same number of fields as the first,
+// but keeping track of the line number and raw text of each record so that problems
+// can be reported usefully.
+type recordReader struct {
+	lines           *bufio.Reader // The source of the CSV text
+	line            int           // The number of lines consumed so far
+	fieldsPerRecord int           // The number of fields expected in each record, zero until the first is read, negative for any number
+}
+
+// newRecordReader returns a recordReader that reads from r.
+func newRecordReader(r io.Reader) *recordReader {
+	return &recordReader{lines: bufio.NewReader(r)}
+}
+
+// Read reads the next record. At the end of the input it returns a nil record and io.EOF.
+//
+// If the record could not be parsed, or does not have the expected number of fields, a
+// *csv.ParseError is returned along with the record so that the caller can choose to
+// report it and carry on. The Fields of such a record are nil if it could not be parsed.
+func (rr *recordReader) Read() (*rawRecord, error) {
+
+	// Gather the lines of the next record, skipping blank lines and continuing
+	// onto the following lines while a quoted field remains open
+	var text strings.Builder
+	startLine := 0
+	for {
+		line, err := rr.lines.ReadString('\n')
+		if line == "" && err != nil {
+			if err == io.EOF && text.Len() > 0 {
+				break
+			}
+			return nil, err
+		}
+		rr.line++
+
+		// Skip blank lines between records
+		trimmed := strings.TrimRight(line, "\r\n")
+		if text.Len() == 0 && trimmed == "" {
+			continue
+		}
+
+		// Accumulate the line, stopping unless a quoted field remains open. A quote
+		// anywhere but at the start of a field does not open one, so a stray quote
+		// costs no more than its own line, as it does with csv.Reader.
+		if text.Len() == 0 {
+			startLine = rr.line
+		} else {
+			text.WriteString("\n")
+		}
+		text.WriteString(trimmed)
+		if !inQuotedField(text.String()) || err != nil {
+			break
+		}
+	}
+
+	// Parse the record text
+	record := &rawRecord{Line: startLine, Text: text.String()}
+	fields, err := csv.NewReader(strings.NewReader(record.Text)).Read()
+	if err != nil {
+		if parseErr, ok := err.(*csv.ParseError); ok {
+			return record, &csv.ParseError{
+				StartLine: startLine + parseErr.StartLine - 1,
+				Line:      startLine + parseErr.Line - 1,
+				Column:    parseErr.Column,
+				Err:       parseErr.Err,
+			}
+		}
+		return record, &csv.ParseError{StartLine: startLine, Line: startLine, Column: 1, Err: err}
+	}
+	record.Fields = fields
+
+	// Check the number of fields in the same way as csv.Reader would
+	if rr.fieldsPerRecord == 0 {
+		rr.fieldsPerRecord = len(fields)
+	} else if rr.fieldsPerRecord > 0 && len(fields) != rr.fieldsPerRecord {
+		return record, &csv.ParseError{StartLine: startLine, Line: startLine, Column: 1, Err: csv.ErrFieldCount}
+	}
+	return record, nil
+}
+
+// inQuotedField returns true if the given record text ends inside a quoted field, that is
+// a field that starts with a quote that has not yet been closed.
+func inQuotedField(text string) bool {
+	quoted, fieldStart := false, true
+	for index := 0; index < len(text); index++ {
+		switch c := text[index]; {
+		case quoted && c == '"' && index+1 < len(text) && text[index+1] == '"':
+			index++
+		case quoted && c == '"':
+			quoted = false
+		case quoted:
+		case c == '"' && fieldStart:
+			quoted = true
+		}
+		fieldStart = !quoted && text[index] == ','
+	}
+	return quoted
+}
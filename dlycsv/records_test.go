@@ -0,0 +1,89 @@
+package dlycsv
+
+// Unit tests for the line tracking CSV record reader.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestRecordReaderLines confirms that blank lines are skipped and that quoted fields
+// may span lines, with each record reporting the line on which it started.
+func TestRecordReaderLines(t *testing.T) {
+
+	reader := newRecordReader(strings.NewReader("a,b\r\n\r\n\"multi\nline\",c\nd,e"))
+
+	record, err := reader.Read()
+	require.Nil(t, err, "first record failed: %v", err)
+	require.Equal(t, 1, record.Line, "wrong first line number")
+	require.Equal(t, []string{"a", "b"}, record.Fields)
+
+	record, err = reader.Read()
+	require.Nil(t, err, "second record failed: %v", err)
+	require.Equal(t, 3, record.Line, "wrong second line number")
+	require.Equal(t, "\"multi\nline\",c", record.Text, "wrong raw text")
+	require.Equal(t, []string{"multi\nline", "c"}, record.Fields)
+
+	record, err = reader.Read()
+	require.Nil(t, err, "third record without a line terminator failed: %v", err)
+	require.Equal(t, 5, record.Line, "wrong third line number")
+	require.Equal(t, []string{"d", "e"}, record.Fields)
+
+	_, err = reader.Read()
+	require.Equal(t, io.EOF, err, "expected the end of the input")
+}
+
+// TestRecordReaderFieldCount confirms that a record with the wrong number of fields
+// is reported along with its line number, without stopping the reader.
+func TestRecordReaderFieldCount(t *testing.T) {
+
+	reader := newRecordReader(strings.NewReader("a,b\nc,d,e\nf,g\n"))
+	_, err := reader.Read()
+	require.Nil(t, err, "first record failed: %v", err)
+
+	// The second record has one field too many
+	record, err := reader.Read()
+	require.NotNil(t, err, "expected a field count error")
+	require.True(t, errors.Is(err, csv.ErrFieldCount), "expected a field count error")
+	require.Contains(t, err.Error(), "line 2")
+	require.Equal(t, "c,d,e", record.Text, "wrong raw text")
+
+	// The third record is fine
+	record, err = reader.Read()
+	require.Nil(t, err, "third record failed: %v", err)
+	require.Equal(t, 3, record.Line, "wrong third line number")
+}
+
+// TestRecordReaderStrayQuote confirms that a quote in the middle of an unquoted field does
+// not pull the following lines into its record, so the good lines after it survive.
+func TestRecordReaderStrayQuote(t *testing.T) {
+
+	reader := newRecordReader(strings.NewReader("a,b\nc,5\" cuff\nd,e\nf,\"g\"\"h\"\n"))
+	_, err := reader.Read()
+	require.Nil(t, err, "first record failed: %v", err)
+
+	// The stray quote spoils its own line and no more
+	record, err := reader.Read()
+	require.NotNil(t, err, "expected a bare quote error")
+	require.True(t, errors.Is(err, csv.ErrBareQuote), "expected a bare quote error: %v", err)
+	require.Equal(t, 2, record.Line, "wrong line number")
+	require.Equal(t, "c,5\" cuff", record.Text, "wrong raw text")
+
+	// The lines after it are read as they should be
+	record, err = reader.Read()
+	require.Nil(t, err, "third record failed: %v", err)
+	require.Equal(t, []string{"d", "e"}, record.Fields)
+	record, err = reader.Read()
+	require.Nil(t, err, "fourth record failed: %v", err)
+	require.Equal(t, 4, record.Line, "wrong fourth line number")
+	require.Equal(t, []string{"f", "g\"h"}, record.Fields)
+}
@@ -0,0 +1,98 @@
+package dlycsv
+
+// The records of an input that were rejected rather than converted into readings, or
+// kept despite a problem, and the machine-readable report that lists them.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// Reject describes an input record that was dropped rather than converted into a reading,
+// or that was only kept by flagging or fixing an implausible reading.
+type Reject struct {
+	Source  string  // The name of the input that the record came from
+	Line    int     // The line of the input on which the record started, counting from 1
+	Text    string  // The raw text of the record, empty if it is not available
+	Reason  string  // Why the record was rejected, flagged or fixed
+	Outcome Outcome // What became of the record
+}
+
+// Outcome records what became of a problem record. The zero value, Rejected, is the
+// outcome of every record that is not a valid reading.
+type Outcome int
+
+// The possible outcomes.
+const (
+	Rejected Outcome = iota // The record was dropped
+	Flagged                 // The reading was kept, flagged with its problem
+	Fixed                   // The reading was kept after its problem was fixed
+)
+
+// The names of the outcomes, as written in the rejects report.
+var outcomeNames = []string{"rejected", "flagged", "fixed"}
+
+// String returns the name of the outcome.
+func (o Outcome) String() string {
+	if o < 0 || int(o) >= len(outcomeNames) {
+		return fmt.Sprintf("Outcome(%d)", int(o))
+	}
+	return outcomeNames[o]
+}
+
+// The column names of the rejects report.
+var rejectsHeader = []string{"Source", "Line", "Text", "Reason", "Outcome"}
+
+// WriteRejects writes the given problem records to w as CSV data, one record per line,
+// preceded by a header record naming the source, line, text, reason and outcome columns.
+func WriteRejects(w io.Writer, rejects []Reject) error {
+	records := [][]string{rejectsHeader}
+	for _, reject := range rejects {
+		records = append(records, []string{
+			reject.Source, strconv.Itoa(reject.Line), reject.Text, reject.Reason, reject.Outcome.String(),
+		})
+	}
+	if err := csv.NewWriter(w).WriteAll(records); err != nil {
+		return classifiedErrorf(OutputError, "failed to write rejects report: %w", err)
+	}
+	return nil
+}
+
+// WriteRejectsFile writes the rejects report to the file at the given path, replacing any
+// existing file, or to standard error if the path is StdioPath, so that the report is kept
+// apart from any output written to standard output.
+func WriteRejectsFile(path string, rejects []Reject) error {
+
+	// Standard error needs no opening (or closing)
+	if path == StdioPath {
+		return WriteRejects(os.Stderr, rejects)
+	}
+
+	// Write the report, making sure that the file gets closed whatever happens
+	file, err := os.Create(path)
+	if err != nil {
+		return classifiedErrorf(OutputError, "could not create rejects report file: %w", err)
+	}
+	err = WriteRejects(file, rejects)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = classifiedErrorf(OutputError, "failed to close rejects report file: %w", closeErr)
+	}
+	return err
+}
+
+// reportRejects passes each of the rejects to the handler of the options, if it has one.
+func reportRejects(rejects []Reject, opts Options) {
+	if opts.Rejected == nil {
+		return
+	}
+	for _, reject := range rejects {
+		opts.Rejected(reject)
+	}
+}
@@ -0,0 +1,88 @@
+package dlycsv
+
+// Unit tests for lenient parsing and the rejects report.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestLenientBadBody confirms that lenient parsing rejects the records of the bad body
+// test file that strict parsing fails on, reporting every record that was dropped.
+func TestLenientBadBody(t *testing.T) {
+
+	// Collect the rejects as they are reported
+	var rejects []Reject
+	opts := Options{Lenient: true, Rejected: func(reject Reject) { rejects = append(rejects, reject) }}
+	summary, err := SummarizeFile("../testdata/badbody.in.csv", opts)
+	require.Nil(t, err, "lenient parsing should have succeeded: %v", err)
+	require.Equal(t, 21, summary.Readings, "wrong number of readings")
+	require.Equal(t, 3, summary.Discarded, "wrong number of discarded records")
+	require.Equal(t, summary.Rejects, rejects, "the handler should have been given every reject")
+
+	// Each reject says where it came from and why it was dropped
+	require.Equal(t, Reject{
+		Source: "../testdata/badbody.in.csv",
+		Line:   7,
+		Text:   "Apr 29 2020 06:58:02,94,66,50,First reading, this field is invalid, a field too far",
+		Reason: "wrong number of fields",
+	}, rejects[0])
+	require.Equal(t, 10, rejects[1].Line)
+	require.Equal(t, `"Invalid" is not a recognized date or time`, rejects[1].Reason)
+	require.Equal(t, 18, rejects[2].Line)
+}
+
+// TestRejectsReport confirms the content of the rejects report.
+func TestRejectsReport(t *testing.T) {
+	rejects := []Reject{
+		{Source: "history.csv", Line: 3, Text: "May 1 2020 07:00,abc,80,60,", Reason: `systolic value "abc" is not a whole number`},
+		{Source: "history.csv", Line: 9, Text: "garbage", Reason: "too few fields"},
+	}
+	var output bytes.Buffer
+	require.Nil(t, WriteRejects(&output, rejects))
+	require.Equal(t, "Source,Line,Text,Reason,Outcome\n"+
+		`history.csv,3,"May 1 2020 07:00,abc,80,60,","systolic value ""abc"" is not a whole number",rejected`+"\n"+
+		"history.csv,9,garbage,too few fields,rejected\n", output.String())
+
+	// The report can also be written to a file
+	dir, err := ioutil.TempDir("", "bpdaily-rejects")
+	require.Nil(t, err, "could not create temporary directory: %v", err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rejects.csv")
+	require.Nil(t, WriteRejectsFile(path, rejects))
+	content, err := ioutil.ReadFile(path)
+	require.Nil(t, err, "could not read rejects report: %v", err)
+	require.Equal(t, output.String(), string(content))
+
+	// A report that cannot be created is an output error
+	err = WriteRejectsFile(dir, rejects)
+	require.Equal(t, OutputError, ClassOf(err), "a directory should not be writable as a report")
+}
+
+// TestLenientStrayQuote confirms that lenient parsing rejects no more than the line with a
+// stray quote in its note, keeping the readings that follow it.
+func TestLenientStrayQuote(t *testing.T) {
+	input := "Date Time,Systolic,Diastolic,Pulse,Note\n" +
+		"May 28 2020 20:59:29,92,66,52,5\" cuff\n" +
+		"May 28 2020 06:18:27,92,68,57,\n" +
+		"May 27 2020 06:10:00,94,70,55,\n"
+	var rejects []Reject
+	opts := Options{Lenient: true, Rejected: func(reject Reject) { rejects = append(rejects, reject) }}
+	readings, err := ParseReadings(context.Background(), strings.NewReader(input), opts)
+	require.Nil(t, err, "lenient parsing should have succeeded: %v", err)
+	require.Len(t, readings, 2, "the good readings should have survived")
+	require.Len(t, rejects, 1, "only the line with the stray quote should be rejected")
+	require.Equal(t, 2, rejects[0].Line)
+	require.Equal(t, "May 28 2020 20:59:29,92,66,52,5\" cuff", rejects[0].Text)
+}
@@ -0,0 +1,181 @@
+package dlycsv
+
+// Rendering of the daily readings as a self-contained HTML report, with an embedded
+// chart, that can be emailed or printed for a clinic visit.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// reportData is what the report template is filled in from.
+type reportData struct {
+	Title    string        // The title of the report
+	Period   string        // The dates that the report covers
+	Chart    template.HTML // The SVG chart of the readings
+	Averages []reportRow   // The averages over the whole period, and over each slot
+	Days     []reportDay   // The readings of each day
+	Classify bool          // True if the averages and days are classified
+}
+
+// reportRow is one line of the period averages table.
+type reportRow struct {
+	Name      string // What the averages are of
+	Readings  int    // The number of readings averaged
+	Systolic  string // The mean systolic pressure
+	Diastolic string // The mean diastolic pressure
+	Pulse     string // The mean pulse rate
+	Category  string // The category of the mean pressures, if classified
+	Color     string // The color that highlights the category
+}
+
+// reportDay is one line of the per-day table.
+type reportDay struct {
+	Date     string   // The date of the day
+	Readings []string // Each reading of the day, as time, pressures and pulse
+	Mean     string   // The mean pressures of the day
+	Notes    string   // The notes of the readings of the day
+	Category string   // The category of the mean pressures, if classified
+	Color    string   // The color that highlights the category
+}
+
+// The template of the report. Everything the report needs is embedded in it, so that
+// it can be sent or printed on its own.
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.5em; margin-bottom: 0.2em; }
+.period { color: #555; margin-top: 0; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+th { background: #f2f2f2; }
+td.number { text-align: right; }
+.chart svg { max-width: 100%; height: auto; }
+@media print { body { margin: 0; } .chart { page-break-after: always; } }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="period">{{.Period}}</p>
+<div class="chart">{{.Chart}}</div>
+<h2>Averages</h2>
+<table>
+<tr><th></th><th>Readings</th><th>Systolic</th><th>Diastolic</th><th>Pulse</th>{{if .Classify}}<th>Category</th>{{end}}</tr>
+{{range .Averages}}<tr><td>{{.Name}}</td><td class="number">{{.Readings}}</td><td class="number">{{.Systolic}}</td><td class="number">{{.Diastolic}}</td><td class="number">{{.Pulse}}</td>{{if $.Classify}}<td style="border-left: 6px solid {{.Color}}">{{.Category}}</td>{{end}}</tr>
+{{end}}</table>
+<h2>Daily Readings</h2>
+<table>
+<tr><th>Date</th><th>Readings</th><th>Mean</th>{{if .Classify}}<th>Category</th>{{end}}<th>Notes</th></tr>
+{{range .Days}}<tr><td>{{.Date}}</td><td>{{range $index, $reading := .Readings}}{{if $index}}<br>{{end}}{{$reading}}{{end}}</td><td class="number">{{.Mean}}</td>{{if $.Classify}}<td style="border-left: 6px solid {{.Color}}">{{.Category}}</td>{{end}}<td>{{.Notes}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// WriteHTML writes the given daily groups to w as a self-contained HTML report: the SVG
+// chart drawn by WriteSVG, the average pressures and pulse over the whole period, and
+// over each slot if the options define slots, and a table of each day's readings, mean
+// pressures and notes. If the options ask for readings to be classified, the averages and
+// the days are given the category of their mean pressures according to the guideline of
+// the options. The policy and slots of the options select the readings of each day, just
+// as they do for the daily CSV output.
+func WriteHTML(w io.Writer, groups []DailyGroup, opts Options) error {
+	if err := reportTemplate.Execute(w, buildReport(groups, opts)); err != nil {
+		return classifiedErrorf(OutputError, "failed to write report to output file: %w", err)
+	}
+	return nil
+}
+
+// buildReport gathers the content of the report.
+func buildReport(groups []DailyGroup, opts Options) *reportData {
+
+	report := &reportData{
+		Title:    "Blood Pressure Report",
+		Chart:    template.HTML(renderChart(groups, opts)),
+		Classify: opts.Classify,
+	}
+	guideline := guidelineOrDefault(opts)
+
+	// Describe each day, gathering all of the readings as we go
+	layout := newDailyLayout(groups, opts)
+	var all []Reading
+	bySlot := make([][]Reading, len(opts.Slots))
+	for _, group := range groups {
+		var readings []Reading
+		for index, slotReadings := range layout.columnSets(group) {
+			readings = append(readings, slotReadings...)
+			if index < len(bySlot) {
+				bySlot[index] = append(bySlot[index], slotReadings...)
+			}
+		}
+		if len(readings) == 0 {
+			continue
+		}
+		all = append(all, readings...)
+		report.Days = append(report.Days, buildReportDay(group, readings, guideline))
+	}
+
+	// Say what period the report covers
+	if len(report.Days) == 0 {
+		report.Period = "No readings"
+		return report
+	}
+	report.Period = fmt.Sprintf("%s to %s, %d readings over %d days",
+		report.Days[0].Date, report.Days[len(report.Days)-1].Date, len(all), len(report.Days))
+
+	// Average the whole period, then each slot
+	report.Averages = append(report.Averages, buildReportRow("Whole period", all, guideline))
+	for index, slot := range opts.Slots {
+		report.Averages = append(report.Averages, buildReportRow(slot.Name, bySlot[index], guideline))
+	}
+	return report
+}
+
+// buildReportDay describes the readings of a single day.
+func buildReportDay(group DailyGroup, readings []Reading, guideline *Guideline) reportDay {
+	day := reportDay{Date: group.Date.Format("Mon 2006-01-02")}
+	var notes []string
+	for _, reading := range readings {
+		text := fmt.Sprintf("%s %d/%d", reading.Time.Format("15:04"), reading.Systolic, reading.Diastolic)
+		if reading.Pulse != 0 {
+			text += fmt.Sprintf(" pulse %d", reading.Pulse)
+		}
+		day.Readings = append(day.Readings, text)
+		if reading.Note != "" {
+			notes = append(notes, reading.Note)
+		}
+	}
+	systolic, diastolic, _ := readingRanges(readings)
+	day.Mean = fmt.Sprintf("%d/%d", roundToInt(systolic.Mean), roundToInt(diastolic.Mean))
+	day.Notes = strings.Join(notes, "; ")
+	category := guideline.ClassifyMeans(systolic.Mean, diastolic.Mean)
+	day.Category, day.Color = guideline.Label(category), category.color()
+	return day
+}
+
+// buildReportRow averages the given readings for the period averages table.
+func buildReportRow(name string, readings []Reading, guideline *Guideline) reportRow {
+	row := reportRow{Name: name, Readings: len(readings)}
+	if len(readings) == 0 {
+		return row
+	}
+	systolic, diastolic, pulse := readingRanges(readings)
+	row.Systolic = fmt.Sprintf("%.1f", systolic.Mean)
+	row.Diastolic = fmt.Sprintf("%.1f", diastolic.Mean)
+	if pulse.Max > 0 {
+		row.Pulse = fmt.Sprintf("%.1f", pulse.Mean)
+	}
+	category := guideline.ClassifyMeans(systolic.Mean, diastolic.Mean)
+	row.Category, row.Color = guideline.Label(category), category.color()
+	return row
+}
@@ -0,0 +1,64 @@
+package dlycsv
+
+// Unit tests for the HTML report output.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestConvertToHTML reports on the happy path input and checks the content of the report.
+func TestConvertToHTML(t *testing.T) {
+
+	inputFile, err := os.Open("../testdata/happypath.in.csv")
+	require.Nil(t, err, "could not open input file: %v", err)
+	defer inputFile.Close()
+
+	// Report into a buffer, with morning and evening slots
+	slots, err := ParseSlots("Morning=04:00-12:00,Evening=12:00-04:00")
+	require.Nil(t, err, "ParseSlots returned an error: %v", err)
+	var output bytes.Buffer
+	err = Convert(context.Background(), inputFile, &output, Options{Output: HTMLOutput, Slots: slots})
+	require.Nil(t, err, "Convert returned an error: %v", err)
+	html := output.String()
+
+	// The chart is embedded, and the period is described
+	require.Contains(t, html, "<div class=\"chart\"><svg xmlns=\"http://www.w3.org/2000/svg\"")
+	require.Contains(t, html, "Sun 2020-04-26 to Thu 2020-05-28, 22 readings over 14 days")
+
+	// There are averages for the whole period and for each slot
+	require.Contains(t, html, "<td>Whole period</td><td class=\"number\">22</td><td class=\"number\">96.3</td>")
+	require.Contains(t, html, "<td>Morning</td><td class=\"number\">14</td>")
+	require.Contains(t, html, "<td>Evening</td><td class=\"number\">8</td>")
+
+	// And each day has its readings, its mean, rounded half away from zero, and its notes
+	require.Contains(t, html, "<td>Tue 2020-04-28</td><td>06:06 92/67 pulse 57<br>21:37 93/65 pulse 63</td><td class=\"number\">93/66</td>")
+	require.Contains(t, html, "<td>First reading; Second reading; Third reading</td>")
+}
+
+// TestReportEscapesNotes confirms that notes cannot inject markup into the report.
+func TestReportEscapesNotes(t *testing.T) {
+	readings := []Reading{{Time: time.Date(2020, 5, 1, 7, 0, 0, 0, time.UTC), Systolic: 120, Diastolic: 80, Note: "<b>dizzy</b>"}}
+	var output bytes.Buffer
+	err := WriteHTML(&output, GroupByDay(readings), Options{})
+	require.Nil(t, err, "WriteHTML returned an error: %v", err)
+	require.Contains(t, output.String(), "&lt;b&gt;dizzy&lt;/b&gt;")
+	require.Contains(t, output.String(), "07:00 120/80</td>", "a reading without a pulse should not show one")
+}
+
+// TestEmptyReport confirms that a report with no readings says so.
+func TestEmptyReport(t *testing.T) {
+	var output bytes.Buffer
+	err := WriteHTML(&output, nil, Options{})
+	require.Nil(t, err, "WriteHTML returned an error: %v", err)
+	require.Contains(t, output.String(), "<p class=\"period\">No readings</p>")
+}
@@ -0,0 +1,145 @@
+package dlycsv
+
+// Functions to place readings into named time of day slots, such as morning and
+// evening, so that each slot always occupies the same columns of the daily output.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Slot is a named range of the time of day. A slot whose end is before its start wraps
+// past midnight, so that a slot from 18:00 to 04:00 covers late evening and the small hours.
+type Slot struct {
+	Name  string        // The name of the slot, used to name its output columns
+	Start time.Duration // The time of day at which the slot starts, inclusive
+	End   time.Duration // The time of day at which the slot ends, exclusive
+}
+
+// Contains returns true if the time of day of the given time falls within the slot.
+func (s Slot) Contains(t time.Time) bool {
+	offset := timeOfDay(t)
+	if s.Start < s.End {
+		return offset >= s.Start && offset < s.End
+	}
+	return offset >= s.Start || offset < s.End
+}
+
+// String returns the slot in the form accepted by ParseSlots.
+func (s Slot) String() string {
+	return fmt.Sprintf("%s=%s-%s", s.Name, formatTimeOfDay(s.Start), formatTimeOfDay(s.End))
+}
+
+// ParseSlots parses a comma separated list of named time ranges, each in the form
+// name=hh:mm-hh:mm, for example "Morning=04:00-12:00,Evening=18:00-04:00". An end
+// time of 24:00 may be used for a slot that runs up to midnight.
+func ParseSlots(spec string) ([]Slot, error) {
+
+	var slots []Slot
+	names := make(map[string]bool)
+	for _, item := range strings.Split(spec, ",") {
+
+		// Split the item into its name and time range
+		item = strings.TrimSpace(item)
+		equals := strings.Index(item, "=")
+		if equals < 0 {
+			return nil, fmt.Errorf("time slot %q is not in the form name=hh:mm-hh:mm", item)
+		}
+		name := strings.TrimSpace(item[:equals])
+		times := strings.Split(item[equals+1:], "-")
+		if name == "" || len(times) != 2 {
+			return nil, fmt.Errorf("time slot %q is not in the form name=hh:mm-hh:mm", item)
+		}
+
+		// Slot names become column names so they must be unique
+		if names[name] {
+			return nil, fmt.Errorf("time slot name %q is used more than once", name)
+		}
+		names[name] = true
+
+		// Parse the start and end times
+		start, err := parseTimeOfDay(times[0])
+		if err != nil {
+			return nil, fmt.Errorf("time slot %q has an invalid start time: %w", item, err)
+		}
+		end, err := parseTimeOfDay(times[1])
+		if err != nil {
+			return nil, fmt.Errorf("time slot %q has an invalid end time: %w", item, err)
+		}
+		if start == end || start == 24*time.Hour {
+			return nil, fmt.Errorf("time slot %q has an empty or invalid time range", item)
+		}
+		slots = append(slots, Slot{Name: name, Start: start, End: end % (24 * time.Hour)})
+	}
+	return slots, nil
+}
+
+// parseTimeOfDay parses a time of day in hh:mm form, returning it as the
+// duration since midnight. 24:00 is accepted as the end of the day.
+func parseTimeOfDay(text string) (time.Duration, error) {
+	parts := strings.Split(strings.TrimSpace(text), ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("%q is not in the form hh:mm", text)
+	}
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil || hours < 0 || hours > 24 {
+		return 0, fmt.Errorf("%q does not have a valid hour", text)
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil || minutes < 0 || minutes > 59 || (hours == 24 && minutes != 0) {
+		return 0, fmt.Errorf("%q does not have a valid minute", text)
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+// formatTimeOfDay formats a duration since midnight in hh:mm form.
+func formatTimeOfDay(offset time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(offset/time.Hour), int(offset%time.Hour/time.Minute))
+}
+
+// timeOfDay returns the time elapsed on the clock since midnight of the given time.
+func timeOfDay(t time.Time) time.Duration {
+	hour, minute, second := t.Clock()
+	return time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute +
+		time.Duration(second)*time.Second + time.Duration(t.Nanosecond())
+}
+
+// slotFor returns the index of the first of the given slots that contains the time of
+// the reading, or -1 if none of them do.
+func slotFor(slots []Slot, reading Reading) int {
+	for index, slot := range slots {
+		if slot.Contains(reading.Time) {
+			return index
+		}
+	}
+	return -1
+}
+
+// BySlot returns the readings of the day arranged by the given slots: one slice of
+// readings, in time order, for each slot. Readings that do not fall within any of the
+// slots are left out. Where slots overlap, a reading is placed in the first that fits.
+func (g DailyGroup) BySlot(slots []Slot) [][]Reading {
+	bySlot := make([][]Reading, len(slots))
+	for _, reading := range g.Readings {
+		if index := slotFor(slots, reading); index >= 0 {
+			bySlot[index] = append(bySlot[index], reading)
+		}
+	}
+	return bySlot
+}
+
+// resolvedSlots returns the readings of the day arranged by the given slots, with the
+// policy applied to the readings of each slot.
+func resolvedSlots(group DailyGroup, slots []Slot, policy Policy) [][]Reading {
+	bySlot := group.BySlot(slots)
+	for index, readings := range bySlot {
+		bySlot[index] = policy.Resolve(readings)
+	}
+	return bySlot
+}
@@ -0,0 +1,79 @@
+package dlycsv
+
+// Unit tests for the time of day slot functions.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseSlots confirms that a slot list is parsed, including an end of day and
+// a slot that wraps past midnight.
+func TestParseSlots(t *testing.T) {
+
+	slots, err := ParseSlots("Morning=04:00-12:00, Afternoon=12:00-18:00,Evening=18:00-24:00,Night=22:30-04:00")
+	require.Nil(t, err, "ParseSlots returned an error: %v", err)
+	require.Equal(t, 4, len(slots), "wrong number of slots")
+	require.Equal(t, Slot{Name: "Afternoon", Start: 12 * time.Hour, End: 18 * time.Hour}, slots[1])
+	require.Equal(t, time.Duration(0), slots[2].End, "24:00 should be midnight")
+	require.Equal(t, "Night=22:30-04:00", slots[3].String())
+
+	// A reading in the small hours should be in the night slot only
+	smallHours := time.Date(2020, 4, 29, 1, 15, 0, 0, time.UTC)
+	require.True(t, slots[3].Contains(smallHours), "night slot should wrap past midnight")
+	require.False(t, slots[2].Contains(smallHours), "evening slot should end at midnight")
+	require.True(t, slots[2].Contains(smallHours.Add(22*time.Hour)), "evening slot should include 23:15")
+}
+
+// TestParseSlotsErrors confirms that invalid slot lists are rejected.
+func TestParseSlotsErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"Morning",
+		"=04:00-12:00",
+		"Morning=04:00",
+		"Morning=4-12",
+		"Morning=25:00-12:00",
+		"Morning=04:60-12:00",
+		"Morning=04:00-04:00",
+		"Morning=24:00-04:00",
+		"Morning=04:00-12:00,Morning=12:00-18:00",
+	} {
+		_, err := ParseSlots(spec)
+		require.NotNil(t, err, "expected %q to be rejected", spec)
+	}
+}
+
+// TestConvertWithSlots confirms that readings are laid out in the columns of their slots,
+// with blank columns for empty slots and readings outside every slot left out.
+func TestConvertWithSlots(t *testing.T) {
+
+	input := "Date Time,Systolic,Diastolic,Pulse,Note\n" +
+		"Apr 29 2020 21:30:15,98,79,67,Third\n" +
+		"Apr 28 2020 21:37:54,93,65,63,\n" +
+		"Apr 29 2020 06:58:02,94,66,50,First\n" +
+		"Apr 29 2020 18:42:54,101,77,63,Second\n" +
+		"Apr 29 2020 14:00:00,99,70,60,Afternoon\n"
+	slots, err := ParseSlots("Morning=04:00-12:00,Evening=18:00-04:00")
+	require.Nil(t, err, "ParseSlots returned an error: %v", err)
+
+	var output bytes.Buffer
+	err = Convert(context.Background(), strings.NewReader(input), &output, Options{Slots: slots})
+	require.Nil(t, err, "Convert returned an error: %v", err)
+
+	expected := "Morning Date Time,Morning Systolic,Morning Diastolic,Morning Pulse,Morning Note," +
+		"Evening Date Time 1,Evening Systolic 1,Evening Diastolic 1,Evening Pulse 1,Evening Note 1," +
+		"Evening Date Time 2,Evening Systolic 2,Evening Diastolic 2,Evening Pulse 2,Evening Note 2\n" +
+		",,,,,2020-04-28 21:37:54,93,65,63,,,,,,\n" +
+		"2020-04-29 06:58:02,94,66,50,First,2020-04-29 18:42:54,101,77,63,Second,2020-04-29 21:30:15,98,79,67,Third\n"
+	require.Equal(t, expected, output.String(), "output did not match expected")
+}
@@ -0,0 +1,69 @@
+package dlycsv
+
+// Summary statistics of the readings of a day, for the optional computed columns
+// of the daily output.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"math"
+)
+
+// DailyStats summarizes a set of readings, typically those of a single day.
+type DailyStats struct {
+	Count                int     // The number of readings
+	Systolic             Range   // The spread of the systolic pressures
+	Diastolic            Range   // The spread of the diastolic pressures
+	Pulse                Range   // The spread of the recorded pulse rates, zero if none were recorded
+	PulsePressure        float64 // The mean systolic less the mean diastolic pressure
+	MeanArterialPressure float64 // The mean diastolic pressure plus a third of the pulse pressure
+}
+
+// ComputeStats returns the summary statistics of the given readings.
+func ComputeStats(readings []Reading) DailyStats {
+	stats := DailyStats{Count: len(readings)}
+	if len(readings) == 0 {
+		return stats
+	}
+	stats.Systolic, stats.Diastolic, stats.Pulse = readingRanges(readings)
+	stats.PulsePressure = stats.Systolic.Mean - stats.Diastolic.Mean
+	stats.MeanArterialPressure = stats.Diastolic.Mean + stats.PulsePressure/3
+	return stats
+}
+
+// The names of the statistics columns, in the order that they follow the reading columns.
+var statsHeadings = []string{
+	"Mean Systolic", "Min Systolic", "Max Systolic",
+	"Mean Diastolic", "Min Diastolic", "Max Diastolic",
+	"Mean Pulse", "Reading Count", "Pulse Pressure", "Mean Arterial Pressure",
+}
+
+// cells returns the statistics as output cells, in the order of statsHeadings. Means and
+// derived pressures are rounded to one decimal place; values that cannot be computed
+// because there are no readings, or no pulse rates, are left blank.
+func (s DailyStats) cells() []cell {
+	cells := make([]cell, len(statsHeadings))
+	cells[7] = intValue(s.Count)
+	if s.Count == 0 {
+		return cells
+	}
+	cells[0] = decimalValue(s.Systolic.Mean)
+	cells[1] = intValue(s.Systolic.Min)
+	cells[2] = intValue(s.Systolic.Max)
+	cells[3] = decimalValue(s.Diastolic.Mean)
+	cells[4] = intValue(s.Diastolic.Min)
+	cells[5] = intValue(s.Diastolic.Max)
+	if s.Pulse.Max > 0 {
+		cells[6] = decimalValue(s.Pulse.Mean)
+	}
+	cells[8] = decimalValue(s.PulsePressure)
+	cells[9] = decimalValue(s.MeanArterialPressure)
+	return cells
+}
+
+// decimalValue returns a number cell holding the given value rounded to one decimal place.
+func decimalValue(value float64) cell {
+	return cell{kind: numberCell, number: math.Round(value*10) / 10}
+}
@@ -0,0 +1,64 @@
+package dlycsv
+
+// Unit tests for the per-day summary statistics.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// statsReadings returns the readings of a day used by the statistics tests.
+func statsReadings() []Reading {
+	day := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+	return []Reading{
+		{Time: day.Add(7 * time.Hour), Systolic: 120, Diastolic: 80, Pulse: 60},
+		{Time: day.Add(8 * time.Hour), Systolic: 130, Diastolic: 86},
+		{Time: day.Add(20 * time.Hour), Systolic: 125, Diastolic: 83, Pulse: 66},
+	}
+}
+
+// TestComputeStats confirms the statistics of a set of readings.
+func TestComputeStats(t *testing.T) {
+	stats := ComputeStats(statsReadings())
+	require.Equal(t, 3, stats.Count)
+	require.Equal(t, Range{Min: 120, Max: 130, Mean: 125}, stats.Systolic)
+	require.Equal(t, Range{Min: 80, Max: 86, Mean: 83}, stats.Diastolic)
+	require.Equal(t, Range{Min: 60, Max: 66, Mean: 63}, stats.Pulse, "unrecorded pulses should be left out")
+	require.Equal(t, 42.0, stats.PulsePressure)
+	require.Equal(t, 97.0, stats.MeanArterialPressure)
+
+	// Nothing can be computed without readings
+	require.Equal(t, DailyStats{}, ComputeStats(nil))
+}
+
+// TestStatsColumns confirms that the statistics columns are appended to the daily output,
+// computed from the readings before the policy is applied.
+func TestStatsColumns(t *testing.T) {
+	var output bytes.Buffer
+	err := WriteDaily(&output, GroupByDay(statsReadings()), Options{Stats: true, Policy: KeepFirst})
+	require.Nil(t, err, "WriteDaily returned an error: %v", err)
+	lines := strings.Split(output.String(), "\n")
+	require.Equal(t, "Date Time (first),Systolic (first),Diastolic (first),Pulse (first),Note (first),"+
+		"Mean Systolic,Min Systolic,Max Systolic,Mean Diastolic,Min Diastolic,Max Diastolic,"+
+		"Mean Pulse,Reading Count,Pulse Pressure,Mean Arterial Pressure", lines[0])
+	require.Equal(t, "2020-05-01 07:00:00,120,80,60,,125,120,130,83,80,86,63,3,42,97", lines[1])
+}
+
+// TestStatsColumnsLineUp confirms that days with fewer readings are padded so that the
+// statistics columns always line up.
+func TestStatsColumnsLineUp(t *testing.T) {
+	readings := append(statsReadings(), Reading{Time: time.Date(2020, 5, 2, 7, 0, 0, 0, time.UTC), Systolic: 118, Diastolic: 79, Pulse: 58})
+	var output bytes.Buffer
+	err := WriteDaily(&output, GroupByDay(readings), Options{Stats: true})
+	require.Nil(t, err, "WriteDaily returned an error: %v", err)
+	lines := strings.Split(output.String(), "\n")
+	require.Equal(t, "2020-05-02 07:00:00,118,79,58,,,,,,,,,,,,118,118,118,79,79,79,58,1,39,92", lines[2])
+}
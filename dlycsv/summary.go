@@ -0,0 +1,156 @@
+package dlycsv
+
+// Functions to describe the content of a blood pressure CSV file without
+// converting it into a daily file.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Summary describes the readings found in a blood pressure CSV file.
+type Summary struct {
+	Readings   int       // The number of valid readings found
+	Discarded  int       // The number of records that were not valid readings
+	Rejects    []Reject  // The records that were not valid readings, or were flagged or fixed, and why
+	Duplicates int       // The number of readings left out because they repeated a reading of an earlier input
+	Days       int       // The number of distinct days on which readings were taken
+	First      time.Time // The time of the earliest reading
+	Last       time.Time // The time of the latest reading
+	Systolic   Range     // The spread of systolic values
+	Diastolic  Range     // The spread of diastolic values
+	Pulse      Range     // The spread of pulse values
+	Gaps       []Gap     // The streaks of days without readings, between the days chosen as they are by FillGaps
+
+	Trend   *Trend           // The trend of every reading, nil if there are too few readings to have one
+	Rolling []RollingAverage // The rolling averages of the Rolling windows of the options that end on the last day with readings
+}
+
+// Range describes the spread of a set of integer values.
+type Range struct {
+	Min  int     // The lowest value
+	Max  int     // The highest value
+	Mean float64 // The arithmetic mean of the values
+}
+
+// SummarizeBloodPressureCSV reads the blood pressure CSV file at the input path, or standard
+// input if the path is StdioPath, and returns a summary of its content. An error is returned
+// if the file could not be read or is not a blood pressure CSV file; invalid individual
+// records are listed in the summary rather than reported as errors.
+func SummarizeBloodPressureCSV(inputPath string) (*Summary, error) {
+	return SummarizeFile(inputPath, Options{})
+}
+
+// SummarizeFile is the same as SummarizeBloodPressureCSV but takes options, such as the
+// input format, that control how the file is parsed. If the options do not name a source,
+// the input path is used.
+func SummarizeFile(inputPath string, opts Options) (*Summary, error) {
+	return SummarizeFiles([]string{inputPath}, opts)
+}
+
+// SummarizeFiles does the same as SummarizeFile for any number of inputs, merging the
+// readings of every input together in the same way as ConvertFiles.
+func SummarizeFiles(inputPaths []string, opts Options) (*Summary, error) {
+	readings, rejects, duplicates, err := parseFiles(context.Background(), inputPaths, opts)
+	if err != nil {
+		return nil, err
+	}
+	return summarize(readings, rejects, duplicates, opts), nil
+}
+
+// Summarize reads blood pressure CSV data from the given reader and returns a summary of
+// its content, parsed according to the given options. Readings are not merged, as they
+// are not by Convert. Reading stops early, returning the context error, if the context
+// is cancelled.
+func Summarize(ctx context.Context, r io.Reader, opts Options) (*Summary, error) {
+
+	// Parse the readings, keeping those records that were not valid readings
+	readings, rejects, err := parseReadings(ctx, r, opts)
+	if err != nil {
+		return nil, err
+	}
+	return summarize(readings, rejects, 0, opts), nil
+}
+
+// summarize does the work of Summarize once the readings have been parsed and merged,
+// passing the rejects to the Rejected handler of the options.
+func summarize(readings []Reading, rejects []Reject, duplicates int, opts Options) *Summary {
+
+	// Accumulate what we find in each reading
+	reportRejects(rejects, opts)
+	summary := &Summary{Readings: len(readings), Rejects: rejects, Duplicates: duplicates}
+	for _, reject := range rejects {
+		if reject.Outcome == Rejected {
+			summary.Discarded++
+		}
+	}
+	var systolic, diastolic, pulse rangeAccumulator
+	for index, reading := range readings {
+
+		// Track the earliest and latest readings
+		if index == 0 || reading.Time.Before(summary.First) {
+			summary.First = reading.Time
+		}
+		if index == 0 || reading.Time.After(summary.Last) {
+			summary.Last = reading.Time
+		}
+
+		// Accumulate the values
+		systolic.add(reading.Systolic)
+		diastolic.add(reading.Diastolic)
+		if reading.Pulse != 0 {
+			pulse.add(reading.Pulse)
+		}
+	}
+
+	// Fill in the totals and we are done
+	groups := GroupByDayStartingAt(readings, opts.DayStart)
+	summary.Days = len(groups)
+	from, to := opts.calendar()
+	summary.Gaps = FindGaps(groups, from, to)
+	summary.Systolic = systolic.result()
+	summary.Diastolic = diastolic.result()
+	summary.Pulse = pulse.result()
+
+	// The trend covers everything, while the rolling averages look back from the last day
+	summary.Trend = ComputeTrend(readings)
+	if len(groups) > 0 {
+		for _, days := range opts.Rolling {
+			summary.Rolling = append(summary.Rolling, Rolling(groups, groups[len(groups)-1].Date, days))
+		}
+	}
+	return summary
+}
+
+// rangeAccumulator gathers the values needed to produce a Range.
+type rangeAccumulator struct {
+	count int
+	total int
+	min   int
+	max   int
+}
+
+// add accumulates one more value.
+func (acc *rangeAccumulator) add(value int) {
+	if acc.count == 0 || value < acc.min {
+		acc.min = value
+	}
+	if acc.count == 0 || value > acc.max {
+		acc.max = value
+	}
+	acc.count++
+	acc.total += value
+}
+
+// result returns the Range of the values accumulated so far.
+func (acc *rangeAccumulator) result() Range {
+	if acc.count == 0 {
+		return Range{}
+	}
+	return Range{Min: acc.min, Max: acc.max, Mean: float64(acc.total) / float64(acc.count)}
+}
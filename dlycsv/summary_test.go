@@ -0,0 +1,49 @@
+package dlycsv
+
+// Unit tests for the dlycsv summary functions.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestSummarizeHappyPath summarizes a messy but still legal blood pressure CSV file.
+func TestSummarizeHappyPath(t *testing.T) {
+
+	summary, err := SummarizeBloodPressureCSV("../testdata/happypath.in.csv")
+	require.Nil(t, err, "SummarizeBloodPressureCSV returned an error: %v", err)
+
+	// Check the counts and spread of values
+	require.Equal(t, 22, summary.Readings, "wrong number of readings")
+	require.Equal(t, 2, summary.Discarded, "wrong number of discarded records")
+	require.Equal(t, 14, summary.Days, "wrong number of days")
+	require.Equal(t, "2020-04-26 06:16:43", summary.First.Format("2006-01-02 15:04:05"))
+	require.Equal(t, "2020-05-28 20:59:29", summary.Last.Format("2006-01-02 15:04:05"))
+	require.Equal(t, 91, summary.Systolic.Min, "wrong minimum systolic")
+	require.Equal(t, 107, summary.Systolic.Max, "wrong maximum systolic")
+	require.Equal(t, 79, summary.Diastolic.Max, "wrong maximum diastolic")
+}
+
+// TestSummarizeErrorClasses confirms that summary failures are classified.
+func TestSummarizeErrorClasses(t *testing.T) {
+
+	// A missing file is an input error
+	_, err := SummarizeBloodPressureCSV("../no-such/thing.in.csv")
+	require.NotNil(t, err, "expected error because input file did not exist")
+	require.Equal(t, InputError, ClassOf(err), "missing file should be an input error")
+
+	// A bad header is a format error
+	_, err = SummarizeBloodPressureCSV("../testdata/badheader.in.csv")
+	require.NotNil(t, err, "expected error because input file has a bad header")
+	require.Equal(t, FormatError, ClassOf(err), "bad header should be a format error")
+
+	// A corrupt body is also a format error
+	_, err = SummarizeBloodPressureCSV("../testdata/badbody.in.csv")
+	require.NotNil(t, err, "expected error because input file has a bad data set")
+	require.Equal(t, FormatError, ClassOf(err), "bad body should be a format error")
+}
@@ -0,0 +1,187 @@
+package dlycsv
+
+// Rolling averages over windows of calendar days, and the least-squares trend of
+// the readings, so that the direction of long running histories can be seen.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Trend describes the least-squares straight lines through the values of a set of readings
+// plotted against their times.
+type Trend struct {
+	Systolic  float64 // The slope of the systolic pressures, in mmHg per day
+	Diastolic float64 // The slope of the diastolic pressures, in mmHg per day
+	Pulse     float64 // The slope of the recorded pulse rates, in beats per minute per day
+	HasPulse  bool    // True if enough readings recorded a pulse rate for it to have a slope
+}
+
+// ComputeTrend returns the trend of the given readings, or nil if there are too few, or
+// they were all taken at the same time, for there to be one.
+func ComputeTrend(readings []Reading) *Trend {
+	systolic, ok := leastSquaresSlope(readings, func(r Reading) (int, bool) { return r.Systolic, true })
+	if !ok {
+		return nil
+	}
+	trend := &Trend{Systolic: systolic}
+	trend.Diastolic, _ = leastSquaresSlope(readings, func(r Reading) (int, bool) { return r.Diastolic, true })
+	trend.Pulse, trend.HasPulse = leastSquaresSlope(readings, func(r Reading) (int, bool) { return r.Pulse, r.Pulse != 0 })
+	return trend
+}
+
+// leastSquaresSlope returns the slope, per day, of the least-squares straight line through
+// the values of the readings that have one, and false if there is no such line because the
+// readings with values were not taken at two or more different times.
+func leastSquaresSlope(readings []Reading, value func(Reading) (int, bool)) (float64, bool) {
+
+	// Measure times in days from the first reading, for the sake of precision
+	var origin time.Time
+	var xs, ys []float64
+	for _, reading := range readings {
+		if y, ok := value(reading); ok {
+			if len(xs) == 0 {
+				origin = reading.Time
+			}
+			xs = append(xs, reading.Time.Sub(origin).Hours()/24)
+			ys = append(ys, float64(y))
+		}
+	}
+	if len(xs) < 2 {
+		return 0, false
+	}
+
+	// The slope is the covariance of the times and values over the variance of the times
+	var meanX, meanY float64
+	for index := range xs {
+		meanX += xs[index]
+		meanY += ys[index]
+	}
+	meanX /= float64(len(xs))
+	meanY /= float64(len(ys))
+	var covariance, variance float64
+	for index := range xs {
+		covariance += (xs[index] - meanX) * (ys[index] - meanY)
+		variance += (xs[index] - meanX) * (xs[index] - meanX)
+	}
+	if variance == 0 {
+		return 0, false
+	}
+	return covariance / variance, true
+}
+
+// RollingAverage describes the readings of a window of consecutive calendar days.
+type RollingAverage struct {
+	Days  int        // The length of the window, in days
+	End   time.Time  // Midnight at the start of the last day of the window
+	Stats DailyStats // The statistics of the readings taken during the window
+	Trend *Trend     // The trend of the readings taken during the window, nil if there is none
+}
+
+// Rolling returns the rolling average of the given daily groups, which must be in ascending
+// date order, over the window of the given number of days that ends on the day of end. The
+// window is of calendar days, so days without readings take up their share of it; a window
+// without any readings has a Count of zero.
+func Rolling(groups []DailyGroup, end time.Time, days int) RollingAverage {
+	return rolling(groups, end, days, func(group DailyGroup) []Reading { return group.Readings })
+}
+
+// rolling does the work of Rolling, taking the readings of each day that are to be counted
+// from the given function.
+func rolling(groups []DailyGroup, end time.Time, days int, readingsOf func(DailyGroup) []Reading) RollingAverage {
+	var readings []Reading
+	for _, group := range groups {
+		if age := daysBetween(group.Date, end); age >= 0 && age < days {
+			readings = append(readings, readingsOf(group)...)
+		}
+	}
+	return RollingAverage{Days: days, End: dateIn(end, end.Location()), Stats: ComputeStats(readings), Trend: ComputeTrend(readings)}
+}
+
+// ParseRollingWindows parses a comma separated list of rolling average window lengths,
+// in days, such as "7,30".
+func ParseRollingWindows(spec string) ([]int, error) {
+	var windows []int
+	seen := make(map[int]bool)
+	for _, item := range strings.Split(spec, ",") {
+		days, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil || days < 1 {
+			return nil, fmt.Errorf("rolling average window %q is not a whole number of days", item)
+		}
+		if seen[days] {
+			return nil, fmt.Errorf("rolling average window of %d days is given more than once", days)
+		}
+		seen[days] = true
+		windows = append(windows, days)
+	}
+	return windows, nil
+}
+
+// The names of the columns of each rolling average window, less the window length that
+// starts each of them.
+var rollingHeadings = []string{
+	"Day Mean Systolic", "Day Mean Diastolic", "Day Mean Pulse",
+	"Day Systolic Trend", "Day Diastolic Trend", "Day Pulse Trend",
+}
+
+// rollingHeadingsFor returns the names of the columns of the given windows.
+func rollingHeadingsFor(windows []int) []string {
+	var headings []string
+	for _, days := range windows {
+		for _, heading := range rollingHeadings {
+			headings = append(headings, strconv.Itoa(days)+" "+heading)
+		}
+	}
+	return headings
+}
+
+// cells returns the rolling average as output cells, in the order of rollingHeadings. The
+// means are rounded to one decimal place and the trends, which are per day, to two; values
+// that cannot be computed are left blank.
+func (a RollingAverage) cells() []cell {
+	cells := make([]cell, len(rollingHeadings))
+	if a.Stats.Count > 0 {
+		cells[0] = decimalValue(a.Stats.Systolic.Mean)
+		cells[1] = decimalValue(a.Stats.Diastolic.Mean)
+		if a.Stats.Pulse.Max > 0 {
+			cells[2] = decimalValue(a.Stats.Pulse.Mean)
+		}
+	}
+	copy(cells[3:], a.Trend.cells())
+	return cells
+}
+
+// The names of the trend columns of the aggregated output.
+var trendHeadings = []string{"Systolic Trend", "Diastolic Trend", "Pulse Trend"}
+
+// cells returns the slopes of the trend as output cells, in the order of trendHeadings,
+// rounded to two decimal places. A nil trend, or a pulse without a trend, is left blank.
+func (t *Trend) cells() []cell {
+	cells := make([]cell, len(trendHeadings))
+	if t == nil {
+		return cells
+	}
+	cells[0] = slopeValue(t.Systolic)
+	cells[1] = slopeValue(t.Diastolic)
+	if t.HasPulse {
+		cells[2] = slopeValue(t.Pulse)
+	}
+	return cells
+}
+
+// slopeValue returns a number cell holding the given slope rounded to two decimal places,
+// without the minus sign of a slope too shallow to show.
+func slopeValue(value float64) cell {
+	rounded := math.Round(value*100) / 100
+	if rounded == 0 {
+		rounded = 0
+	}
+	return cell{kind: numberCell, number: rounded}
+}
@@ -0,0 +1,122 @@
+package dlycsv
+
+// Unit tests for rolling averages and trends.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestComputeTrend confirms the least-squares slopes of readings that change steadily.
+func TestComputeTrend(t *testing.T) {
+
+	// Systolic rises by 1 a day, diastolic falls by half that, and only two pulses are recorded
+	var readings []Reading
+	for day := 0; day < 5; day++ {
+		reading := Reading{Time: time.Date(2020, 5, 1+day, 7, 0, 0, 0, time.UTC), Systolic: 120 + day, Diastolic: 80 - day/2}
+		if day < 2 {
+			reading.Pulse = 60 + 3*day
+		}
+		readings = append(readings, reading)
+	}
+	trend := ComputeTrend(readings)
+	require.NotNil(t, trend)
+	require.InDelta(t, 1.0, trend.Systolic, 1e-9)
+	require.InDelta(t, -0.5, trend.Diastolic, 1e-9)
+	require.True(t, trend.HasPulse)
+	require.InDelta(t, 3.0, trend.Pulse, 1e-9)
+
+	// A single pulse has no slope
+	readings[1].Pulse = 0
+	trend = ComputeTrend(readings)
+	require.False(t, trend.HasPulse)
+	require.Equal(t, "", trend.cells()[2].String())
+
+	// Neither does a single reading, or readings all taken at once
+	require.Nil(t, ComputeTrend(readings[:1]))
+	require.Nil(t, ComputeTrend([]Reading{readings[0], readings[0]}))
+	require.Equal(t, []string{"", "", ""}, cellsToStrings((*Trend)(nil).cells()))
+}
+
+// TestRolling confirms that rolling averages cover windows of calendar days, not of days
+// with readings.
+func TestRolling(t *testing.T) {
+
+	// Readings on the 1st, 2nd, 5th and 9th of May
+	groups := GroupByDay(gapReadings())
+	groups[3].Readings[0].Systolic = 140
+
+	// The week to the 9th starts on the 3rd, leaving out the first two days
+	average := Rolling(groups, time.Date(2020, 5, 9, 20, 0, 0, 0, time.UTC), 7)
+	require.Equal(t, 7, average.Days)
+	require.Equal(t, time.Date(2020, 5, 9, 0, 0, 0, 0, time.UTC), average.End)
+	require.Equal(t, 2, average.Stats.Count)
+	require.Equal(t, 130.0, average.Stats.Systolic.Mean)
+	require.NotNil(t, average.Trend)
+	require.InDelta(t, 5.0, average.Trend.Systolic, 1e-9)
+
+	// A window that ends in a gap has only what came before it
+	average = Rolling(groups, time.Date(2020, 5, 8, 0, 0, 0, 0, time.UTC), 2)
+	require.Equal(t, 0, average.Stats.Count)
+	require.Nil(t, average.Trend)
+	require.Equal(t, []string{"", "", "", "", "", ""}, cellsToStrings(average.cells()))
+}
+
+// TestParseRollingWindows confirms the window lengths that are accepted.
+func TestParseRollingWindows(t *testing.T) {
+	windows, err := ParseRollingWindows("7, 30")
+	require.Nil(t, err, "ParseRollingWindows returned an error: %v", err)
+	require.Equal(t, []int{7, 30}, windows)
+	for _, spec := range []string{"", "7,", "0", "-7", "7d", "7,7"} {
+		_, err := ParseRollingWindows(spec)
+		require.NotNil(t, err, "%q should have been refused", spec)
+	}
+}
+
+// TestRollingColumns confirms the rolling average columns of the daily output, and that
+// they are kept when the daily file is updated.
+func TestRollingColumns(t *testing.T) {
+	var output bytes.Buffer
+	opts := Options{Rolling: []int{3}}
+	require.Nil(t, WriteDaily(&output, GroupByDay(gapReadings()), opts))
+	require.Equal(t, "Date Time 1,Systolic 1,Diastolic 1,Pulse 1,Note 1,"+
+		"3 Day Mean Systolic,3 Day Mean Diastolic,3 Day Mean Pulse,3 Day Systolic Trend,3 Day Diastolic Trend,3 Day Pulse Trend\n"+
+		"2020-05-01 07:00:00,120,80,60,,120,80,60,,,\n"+
+		"2020-05-02 07:00:00,120,80,60,,120,80,60,0,0,0\n"+
+		"2020-05-05 07:00:00,120,80,60,,120,80,60,,,\n"+
+		"2020-05-09 07:00:00,120,80,60,,120,80,60,,,\n", output.String())
+
+	// The summary has the rolling averages up to the last day, and the overall trend
+	summary, err := Summarize(context.Background(), strings.NewReader("Date Time,Systolic,Diastolic,Pulse,Note\n"+
+		"May 01 2020 07:00:00,120,80,60,\nMay 09 2020 07:00:00,136,88,60,\n"), Options{Rolling: []int{7, 30}})
+	require.Nil(t, err, "Summarize returned an error: %v", err)
+	require.Len(t, summary.Rolling, 2)
+	require.Equal(t, 1, summary.Rolling[0].Stats.Count)
+	require.Equal(t, 2, summary.Rolling[1].Stats.Count)
+	require.InDelta(t, 2.0, summary.Trend.Systolic, 1e-9)
+
+	// Updating a daily file keeps its windows
+	dir, err := ioutil.TempDir("", "bpdaily-rolling")
+	require.Nil(t, err, "could not create temporary directory: %v", err)
+	defer os.RemoveAll(dir)
+	dailyPath := filepath.Join(dir, "daily.csv")
+	require.Nil(t, ConvertFile("../testdata/merge/export-2020-05-01.csv", dailyPath, false, Options{Rolling: []int{7, 30}}))
+	require.Nil(t, UpdateDailyFile(dailyPath, []string{"../testdata/merge/export-2020-05-03.csv"}, Options{}))
+	content, err := ioutil.ReadFile(dailyPath)
+	require.Nil(t, err, "could not read daily file: %v", err)
+	header := strings.SplitN(string(content), "\n", 2)[0]
+	require.True(t, strings.HasSuffix(header, ",7 Day Pulse Trend,30 Day Mean Systolic,30 Day Mean Diastolic,30 Day Mean Pulse,"+
+		"30 Day Systolic Trend,30 Day Diastolic Trend,30 Day Pulse Trend"), "unexpected header: %s", header)
+}
@@ -0,0 +1,351 @@
+package dlycsv
+
+// The reading of existing daily output files, so that new readings can be merged into
+// a long running history without regenerating it from the original exports.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dailyColumnSet describes where the fields of one reading are found in a daily record.
+type dailyColumnSet struct {
+	slot    string         // The name of the slot of the set, empty if there are no slots
+	columns map[string]int // The column in which each field of the reading is found, by field name
+}
+
+// dailyHeader describes the layout of an existing daily file, as found from its header record.
+type dailyHeader struct {
+	sets     []dailyColumnSet // The reading column sets, in column order
+	slots    []string         // The names of the slots, in column order, if any
+	stats    bool             // True if the file has the statistics columns
+	rolling  []int            // The lengths of the rolling average windows whose columns the file has
+	classify bool             // True if the file has the daily category column
+	dates    bool             // True if the file starts each day with a date column, as it does when gaps are filled
+}
+
+// parseDailyHeader works out the layout of a daily file from its header record. Only files
+// that keep every reading can be read back; files written with any other policy are refused.
+func parseDailyHeader(header []string) (*dailyHeader, error) {
+
+	// The statistics and daily category columns are recognized by name
+	trailing := map[string]bool{"Daily Category": true}
+	for _, heading := range statsHeadings {
+		trailing[heading] = true
+	}
+
+	parsed := &dailyHeader{}
+	var prefix, suffix string
+	for index, name := range header {
+		name = strings.TrimPrefix(name, "\ufeff")
+
+		// Only the first column can be the date of the day
+		if index == 0 && name == "Date" {
+			parsed.dates = true
+			continue
+		}
+
+		// Columns after the reading sets must be statistics, rolling averages or the daily category
+		if trailing[name] {
+			parsed.stats = parsed.stats || name != "Daily Category"
+			parsed.classify = parsed.classify || name == "Daily Category"
+			continue
+		}
+		if days, ok := rollingWindowOf(name); ok {
+			if count := len(parsed.rolling); count == 0 || parsed.rolling[count-1] != days {
+				parsed.rolling = append(parsed.rolling, days)
+			}
+			continue
+		}
+
+		// Each set starts with a date time column, named with the prefix and suffix of the set
+		if at := strings.Index(name, "Date Time"); at >= 0 {
+			prefix, suffix = name[:at], name[at+len("Date Time"):]
+			if strings.HasSuffix(suffix, ")") {
+				return nil, fmt.Errorf("column %q was written with a policy that does not keep every reading", name)
+			}
+			slot := strings.TrimSpace(prefix)
+			if slot != "" && (len(parsed.slots) == 0 || parsed.slots[len(parsed.slots)-1] != slot) {
+				parsed.slots = append(parsed.slots, slot)
+			}
+			parsed.sets = append(parsed.sets, dailyColumnSet{slot: slot, columns: map[string]int{"Date Time": index}})
+			continue
+		}
+
+		// Every other column must be a field of the current set
+		if len(parsed.sets) == 0 || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) ||
+			len(name) < len(prefix)+len(suffix) {
+			return nil, fmt.Errorf("column %q is not a daily output column", name)
+		}
+		field := name[len(prefix) : len(name)-len(suffix)]
+		if !dailyFields[field] {
+			return nil, fmt.Errorf("column %q is not a daily output column", name)
+		}
+		parsed.sets[len(parsed.sets)-1].columns[field] = index
+	}
+
+	// We must have found at least one reading
+	if len(parsed.sets) == 0 {
+		return nil, fmt.Errorf("header record has no Date Time column")
+	}
+	for _, set := range parsed.sets {
+		if _, ok := set.columns["Systolic"]; !ok {
+			return nil, fmt.Errorf("a column set has no Systolic column")
+		}
+		if _, ok := set.columns["Diastolic"]; !ok {
+			return nil, fmt.Errorf("a column set has no Diastolic column")
+		}
+	}
+	return parsed, nil
+}
+
+// rollingWindowOf returns the length of the rolling average window of the named column,
+// and false if it is not a rolling average column.
+func rollingWindowOf(name string) (int, bool) {
+	space := strings.Index(name, " ")
+	if space < 0 {
+		return 0, false
+	}
+	days, err := strconv.Atoi(name[:space])
+	if err != nil || days < 1 {
+		return 0, false
+	}
+	for _, heading := range rollingHeadings {
+		if name[space+1:] == heading {
+			return days, true
+		}
+	}
+	return 0, false
+}
+
+// The names of the fields that a reading column set of a daily file can hold, less the
+// date time column that starts the set.
+var dailyFields = map[string]bool{
+	"Systolic": true, "Diastolic": true, "Pulse": true, "Note": true, "Category": true,
+	"Irregular Heartbeat": true, "Body Movement": true, "Measurement Position": true, "Flag": true,
+}
+
+// readings returns the readings held in the column sets of a daily record. Sets whose
+// date time column is blank are padding and hold no reading. The clock readings of the
+// date time columns are taken to be in the given zone.
+func (h *dailyHeader) readings(fields []string, zone *time.Location) ([]Reading, error) {
+
+	// Returns the value of the named field of a set, blank if the set does not have it
+	value := func(set dailyColumnSet, field string) string {
+		index, ok := set.columns[field]
+		if !ok || index >= len(fields) {
+			return ""
+		}
+		return strings.TrimSpace(fields[index])
+	}
+
+	var readings []Reading
+	for _, set := range h.sets {
+		when := value(set, "Date Time")
+		if when == "" {
+			continue
+		}
+
+		// The time and pressures are always present
+		var reading Reading
+		var err error
+		if reading.Time, err = time.ParseInLocation(timestampLayout, when, zone); err != nil {
+			return nil, fmt.Errorf("%q is not a daily output date time", when)
+		}
+		if reading.Systolic, err = parseInteger("systolic", value(set, "Systolic")); err != nil {
+			return nil, err
+		}
+		if reading.Diastolic, err = parseInteger("diastolic", value(set, "Diastolic")); err != nil {
+			return nil, err
+		}
+
+		// The pulse is blank if it was not recorded
+		if pulse := value(set, "Pulse"); pulse != "" {
+			if reading.Pulse, err = parseInteger("pulse", pulse); err != nil {
+				return nil, err
+			}
+		}
+
+		// The rest are as written, with the category recomputed when the file is rewritten
+		reading.Note = value(set, "Note")
+		reading.IrregularHeartbeat = parseDetection(value(set, "Irregular Heartbeat"))
+		reading.BodyMovement = parseDetection(value(set, "Body Movement"))
+		reading.Position = value(set, "Measurement Position")
+		reading.Flag = value(set, "Flag")
+		readings = append(readings, reading)
+	}
+	return readings, nil
+}
+
+// ReadDaily reads CSV data in the form written by WriteDaily, with every reading kept, and
+// returns the readings that it holds. The source name given by the options is recorded in
+// each reading. The times of the input are taken to be in the DisplayZone of the options,
+// or failing that the InputZone, as they are written by WriteDaily. Unlike ParseReadings,
+// any record that cannot be read fails the whole input, since the input is expected to be
+// our own output.
+//
+// Reading stops early, returning the context error, if the context is cancelled.
+func ReadDaily(ctx context.Context, r io.Reader, opts Options) ([]Reading, error) {
+	readings, _, err := readDaily(ctx, r, opts)
+	return readings, err
+}
+
+// readDaily does the work of ReadDaily, additionally returning the layout of the input.
+func readDaily(ctx context.Context, r io.Reader, opts Options) ([]Reading, *dailyHeader, error) {
+
+	// Work out the layout from the header record
+	reader := newRecordReader(&contextReader{ctx: ctx, r: r})
+	headerRecord, err := reader.Read()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, nil, ctxErr
+	} else if err != nil {
+		return nil, nil, classifiedErrorf(FormatError, "failed to read daily file header record: %w", err)
+	}
+	header, err := parseDailyHeader(headerRecord.Fields)
+	if err != nil {
+		return nil, nil, classifiedErrorf(FormatError, "input is not a daily file: %w", err)
+	}
+
+	// Read the readings of each day, whose records stop short after their last reading
+	// unless every day is padded to the full width
+	reader.fieldsPerRecord = -1
+	var readings []Reading
+	for {
+		record, err := reader.Read()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, nil, ctxErr
+		} else if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, classifiedErrorf(FormatError, "failed to read body of daily file: %w", err)
+		}
+		dayReadings, err := header.readings(record.Fields, opts.outputZone())
+		if err != nil {
+			return nil, nil, classifiedErrorf(FormatError, "daily file line %d: %w", record.Line, err)
+		}
+		for _, reading := range dayReadings {
+			reading.Source = opts.Source
+			reading.Line = record.Line
+			readings = append(readings, reading)
+		}
+	}
+	return readings, header, nil
+}
+
+// UpdateDailyFile merges the readings of the given inputs into the existing daily CSV file
+// at the daily path, which must have been written with every reading kept. Readings that
+// the file already holds are left out, as are readings that conflict with them, and the
+// header is widened if a day now has more readings than before.
+//
+// The statistics, rolling average, daily category and date columns are kept if the file has
+// them, whatever the options say, though the options may choose other rolling average
+// windows, and the slots of the options must have the same names as those of the file.
+// The updated file is written to a temporary file alongside the daily file, which then
+// replaces it, so the daily file is never left half written.
+func UpdateDailyFile(dailyPath string, inputPaths []string, opts Options) error {
+
+	// Only a file can be updated in place
+	if dailyPath == StdioPath {
+		return classifiedErrorf(InputError, "the daily file to update must be a file, not standard input")
+	}
+	if format := OutputFormatFor(dailyPath); format != CSVOutput {
+		return classifiedErrorf(OutputError, "only CSV daily files can be updated, not %s files", format)
+	}
+
+	// Read the existing history
+	existing, header, err := readDailyFile(dailyPath, opts)
+	if err != nil {
+		return err
+	}
+
+	// Keep the layout of the existing file
+	opts.Stats = opts.Stats || header.stats
+	opts.Classify = opts.Classify || header.classify
+	if len(opts.Rolling) == 0 {
+		opts.Rolling = header.rolling
+	}
+	opts.FillGaps = opts.FillGaps || header.dates
+	if len(header.slots) > 0 || len(opts.Slots) > 0 {
+		var names []string
+		for _, slot := range opts.Slots {
+			names = append(names, slot.Name)
+		}
+		if strings.Join(names, ",") != strings.Join(header.slots, ",") {
+			return classifiedErrorf(OptionsError, "daily file has the slots %q but the slots %q were given",
+				strings.Join(header.slots, ","), strings.Join(names, ","))
+		}
+	}
+
+	// Read the new readings and merge them into the history, which wins any conflict
+	ctx := context.Background()
+	readings, rejects, _, err := parseFiles(ctx, inputPaths, opts)
+	if err != nil {
+		return err
+	}
+	merged, result := MergeReadings(append(existing, readings...))
+	reportRejects(append(rejects, result.Conflicts...), opts)
+
+	// Write the merged history alongside the original, then replace it
+	opts.Policy = KeepAll
+	return replaceFile(dailyPath, func(w io.Writer) error {
+		return WriteDaily(w, GroupByDayStartingAt(merged, opts.DayStart), opts)
+	})
+}
+
+// readDailyFile reads the readings and layout of the daily file at the given path, written
+// in the time zones of the given options.
+func readDailyFile(dailyPath string, opts Options) ([]Reading, *dailyHeader, error) {
+	dailyFile, err := openInputFile(dailyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer dailyFile.Close()
+	return readDaily(context.Background(), dailyFile, Options{Source: dailyPath, InputZone: opts.InputZone, DisplayZone: opts.DisplayZone})
+}
+
+// replaceFile has the write function write to a temporary file in the same directory as
+// the file at the given path and then, if all went well, renames it over that file, keeping
+// its permissions. The temporary file is removed if anything goes wrong.
+func replaceFile(path string, write func(io.Writer) error) error {
+
+	// The temporary file must be on the same file system for the rename to be atomic
+	info, err := os.Stat(path)
+	if err != nil {
+		return classifiedErrorf(OutputError, "cannot access output file: %w", err)
+	}
+	temporary, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return classifiedErrorf(OutputError, "could not create temporary output file: %w", err)
+	}
+
+	// Write the temporary file, closing it whatever happens
+	err = write(temporary)
+	if closeErr := temporary.Close(); err == nil && closeErr != nil {
+		err = classifiedErrorf(OutputError, "failed to close temporary output file: %w", closeErr)
+	}
+	if err == nil {
+		if chmodErr := os.Chmod(temporary.Name(), info.Mode().Perm()); chmodErr != nil {
+			err = classifiedErrorf(OutputError, "failed to set output file permissions: %w", chmodErr)
+		}
+	}
+	if err == nil {
+		if renameErr := os.Rename(temporary.Name(), path); renameErr != nil {
+			err = classifiedErrorf(OutputError, "failed to replace output file: %w", renameErr)
+		}
+	}
+	if err != nil {
+		os.Remove(temporary.Name())
+	}
+	return err
+}
@@ -0,0 +1,122 @@
+package dlycsv
+
+// Unit tests for reading and updating existing daily files.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestReadDaily confirms that a daily file can be read back into the readings it was written from.
+func TestReadDaily(t *testing.T) {
+
+	// Write the happy path readings as a daily file with every optional column
+	original, err := readFileReadings("../testdata/happypath.in.csv")
+	require.Nil(t, err, "could not read the happy path readings: %v", err)
+	original[0].IrregularHeartbeat = Detected
+	original[1].Flag = "suspect"
+	opts := Options{Stats: true, Classify: true}
+	var daily bytes.Buffer
+	require.Nil(t, WriteDaily(&daily, GroupByDay(original), opts))
+
+	// Reading it back gives the same readings, in day order
+	readings, err := ReadDaily(context.Background(), bytes.NewReader(daily.Bytes()), Options{Source: "daily"})
+	require.Nil(t, err, "ReadDaily returned an error: %v", err)
+	require.Len(t, readings, len(original))
+
+	// Writing those readings again gives the same daily file
+	var again bytes.Buffer
+	require.Nil(t, WriteDaily(&again, GroupByDay(readings), opts))
+	require.Equal(t, daily.String(), again.String())
+
+	// Files without padding, whose records vary in length, can be read back too
+	content, err := ioutil.ReadFile("../testdata/happypath.expected.csv")
+	require.Nil(t, err, "could not read the expected happy path output: %v", err)
+	readings, err = ReadDaily(context.Background(), bytes.NewReader(content), Options{})
+	require.Nil(t, err, "ReadDaily returned an error: %v", err)
+	again.Reset()
+	require.Nil(t, WriteDaily(&again, GroupByDay(readings), Options{}))
+	require.Equal(t, string(content), again.String())
+}
+
+// TestReadDailyRefusals confirms that files that are not daily files, or do not keep
+// every reading, are refused.
+func TestReadDailyRefusals(t *testing.T) {
+	for name, input := range map[string]string{
+		"policy":  "Date Time (first),Systolic (first),Diastolic (first),Pulse (first),Note (first)\n",
+		"unknown": "Date Time 1,Systolic 1,Diastolic 1,Heart 1\n",
+		"export":  "Date,Time,Systolic,Diastolic\n",
+		"body":    "Date Time 1,Systolic 1,Diastolic 1\n2020-05-01 07:00:00,high,80\n",
+	} {
+		_, err := ReadDaily(context.Background(), strings.NewReader(input), Options{})
+		require.NotNil(t, err, "the %s input should have been refused", name)
+		require.Equal(t, FormatError, ClassOf(err), "the %s input should be a format error", name)
+	}
+}
+
+// TestUpdateDailyFile confirms that new readings are merged into an existing daily file,
+// widening its header where a day now has more readings.
+func TestUpdateDailyFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bpdaily-update")
+	require.Nil(t, err, "could not create temporary directory: %v", err)
+	defer os.RemoveAll(dir)
+	dailyPath := filepath.Join(dir, "daily.csv")
+
+	// Start with the older export
+	require.Nil(t, ConvertFile("../testdata/merge/export-2020-05-01.csv", dailyPath, false, Options{Stats: true}))
+	require.Nil(t, os.Chmod(dailyPath, 0600))
+
+	// Then update it with the newer one
+	var rejects []Reject
+	opts := Options{Rejected: func(reject Reject) { rejects = append(rejects, reject) }}
+	err = UpdateDailyFile(dailyPath, []string{"../testdata/merge/export-2020-05-03.csv"}, opts)
+	require.Nil(t, err, "UpdateDailyFile returned an error: %v", err)
+	require.Len(t, rejects, 1, "the edited reading should conflict with the history")
+
+	// The header has been widened and the statistics columns kept
+	content, err := ioutil.ReadFile(dailyPath)
+	require.Nil(t, err, "could not read daily file: %v", err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, lines, 6, "a header and five days were expected")
+	require.True(t, strings.HasPrefix(lines[0], "Date Time 1,Systolic 1,Diastolic 1,Pulse 1,Note 1,"+
+		"Date Time 2,Systolic 2,Diastolic 2,Pulse 2,Note 2,Mean Systolic,"))
+	require.True(t, strings.HasPrefix(lines[3], "2020-05-01 06:22:09,96,66,53,,2020-05-01 22:04:11,92,62,57,,"))
+
+	// The file has kept its permissions and no temporary files are left behind
+	info, err := os.Stat(dailyPath)
+	require.Nil(t, err, "could not stat daily file: %v", err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	files, err := ioutil.ReadDir(dir)
+	require.Nil(t, err, "could not read directory: %v", err)
+	require.Len(t, files, 1, "only the daily file should remain")
+
+	// Updating with slots that the file does not have is refused, leaving the file alone
+	slots, err := ParseSlots("Morning=04:00-12:00")
+	require.Nil(t, err, "ParseSlots returned an error: %v", err)
+	err = UpdateDailyFile(dailyPath, []string{"../testdata/happypath.in.csv"}, Options{Slots: slots})
+	require.Equal(t, OptionsError, ClassOf(err), "mismatched slots should be an options error")
+	unchanged, err := ioutil.ReadFile(dailyPath)
+	require.Nil(t, err, "could not read daily file: %v", err)
+	require.Equal(t, content, unchanged)
+
+	// Only CSV files can be updated
+	err = UpdateDailyFile(filepath.Join(dir, "daily.xlsx"), []string{"../testdata/happypath.in.csv"}, Options{})
+	require.Equal(t, OutputError, ClassOf(err), "a workbook should not be updatable")
+}
+
+// readFileReadings parses the readings of the file at the given path.
+func readFileReadings(path string) ([]Reading, error) {
+	readings, _, err := parseFile(context.Background(), path, Options{})
+	return readings, err
+}
@@ -0,0 +1,66 @@
+package dlycsv
+
+// The input formats of the CSV files exported by the apps of blood pressure
+// monitor vendors other than Omron.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+// WithingsFormat is the InputFormat of the bp.csv file found in a Withings Health Mate
+// data export, with its Date, Heart rate, Systolic, Diastolic and Comments columns.
+var WithingsFormat InputFormat = &columnFormat{
+	name: "withings",
+	columns: map[string]int{
+		"date":       fieldDateTime,
+		"heart rate": fieldPulse,
+		"systolic":   fieldSystolic,
+		"diastolic":  fieldDiastolic,
+		"comments":   fieldNote,
+		"comment":    fieldNote,
+	},
+}
+
+// IHealthFormat is the InputFormat of the CSV files exported by the iHealth MyVitals app,
+// with separate date and time columns and the pulse given in beats per minute.
+var IHealthFormat InputFormat = &columnFormat{
+	name: "ihealth",
+	columns: map[string]int{
+		"date":                fieldDate,
+		"time":                fieldTime,
+		"sys":                 fieldSystolic,
+		"dia":                 fieldDiastolic,
+		"pulse":               fieldPulse,
+		"pul":                 fieldPulse,
+		"arrhythmia":          fieldIrregularHeartbeat,
+		"irregular heartbeat": fieldIrregularHeartbeat,
+		"note":                fieldNote,
+		"notes":               fieldNote,
+		"mood":                fieldIgnored,
+		"activity":            fieldIgnored,
+		"wave":                fieldIgnored,
+		"measurement":         fieldIgnored,
+	},
+	dateLayouts: []string{"Jan 2, 2006", "2006-01-02", "1/2/2006", "01/02/2006"},
+	timeLayouts: []string{"3:04 PM", "03:04 PM", "15:04", "15:04:05"},
+}
+
+// QardioFormat is the InputFormat of the CSV files exported by the Qardio app for the
+// QardioArm monitor, with the pulse given as a heart rate and an irregular heartbeat column.
+var QardioFormat InputFormat = &columnFormat{
+	name: "qardio",
+	columns: map[string]int{
+		"measurement time":    fieldDateTime,
+		"date":                fieldDateTime,
+		"systolic":            fieldSystolic,
+		"diastolic":           fieldDiastolic,
+		"heart rate":          fieldPulse,
+		"pulse":               fieldPulse,
+		"irregular heartbeat": fieldIrregularHeartbeat,
+		"ihb":                 fieldIrregularHeartbeat,
+		"note":                fieldNote,
+		"notes":               fieldNote,
+		"location":            fieldIgnored,
+		"tags":                fieldIgnored,
+	},
+}
@@ -0,0 +1,46 @@
+package dlycsv
+
+// Unit tests for the input formats of vendors other than Omron.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestVendorFormats confirms that each vendor's export is detected and parsed.
+func TestVendorFormats(t *testing.T) {
+
+	expected := time.Date(2023, 5, 28, 20, 59, 0, 0, time.UTC)
+	for name, input := range map[string]string{
+		"withings": "Date,Heart rate,Systolic,Diastolic,Comments\n" +
+			"\"2023-05-28 20:59:00\",64,121,79,After dinner\n",
+		"ihealth": "Date,Time,SYS(mmHg),DIA(mmHg),Pulse(bpm),Arrhythmia,Note\n" +
+			"\"May 28, 2023\",08:59 PM,121,79,64,No,After dinner\n",
+		"qardio": "Measurement time,Systolic,Diastolic,Heart Rate,Irregular heartbeat,Note,Location\n" +
+			"5/28/2023 20:59,121,79,64,no,After dinner,Home\n",
+	} {
+		// Detection should pick the right format, so parsing with it named should work too
+		format, err := LookupFormat(name)
+		require.Nil(t, err, "could not find format %q: %v", name, err)
+		for _, opts := range []Options{{}, {Format: format}} {
+			readings, err := ParseReadings(context.Background(), strings.NewReader(input), opts)
+			require.Nil(t, err, "%s: ParseReadings returned an error: %v", name, err)
+			require.Len(t, readings, 1, "%s: expected one reading", name)
+
+			reading := readings[0]
+			require.Equal(t, expected, reading.Time, "%s: wrong time", name)
+			require.Equal(t, 121, reading.Systolic, "%s: wrong systolic", name)
+			require.Equal(t, 79, reading.Diastolic, "%s: wrong diastolic", name)
+			require.Equal(t, 64, reading.Pulse, "%s: wrong pulse", name)
+			require.Equal(t, "After dinner", reading.Note, "%s: wrong note", name)
+		}
+	}
+}
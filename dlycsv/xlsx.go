@@ -0,0 +1,255 @@
+package dlycsv
+
+// A minimal writer of Excel .xlsx workbooks, so that the daily readings can be opened
+// ready to chart, with numbers as numbers and dates as dates, without any conversion.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The namespaces of the SpreadsheetML parts of a workbook.
+const (
+	spreadsheetNamespace   = "http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+	relationshipsNamespace = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+	packageRelationships   = "http://schemas.openxmlformats.org/package/2006/relationships"
+)
+
+// The indexes of the cell formats defined by the workbook styles.
+const (
+	defaultStyle   = 0 // Unformatted
+	timestampStyle = 1 // A date and time
+	headerStyle    = 2 // Bold text
+	dateStyle      = 3 // A date without a time
+)
+
+// The date from which spreadsheet date serial numbers count days.
+var spreadsheetEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// worksheet is the content of one sheet of a workbook.
+type worksheet struct {
+	name   string   // The name of the sheet, shown on its tab
+	header []string // The column names, written in bold on the first row
+	rows   [][]cell // The rows of data that follow the header
+}
+
+// WriteXLSX writes the given daily groups to w as an Excel workbook with two sheets. The
+// Daily sheet has the same columns as the CSV output of WriteDaily, and the Readings sheet
+// lists every reading on a row of its own. Numbers are written as numbers and reading
+// times as spreadsheet dates, so that the workbook is ready to chart. Gaps are filled in
+// the Daily sheet, if the options ask for it, just as they are by WriteDaily.
+//
+// If the options GroupBy a period longer than a day, the Daily sheet is replaced by a
+// Weekly or Monthly sheet with the same columns as the CSV output of WritePeriods.
+func WriteXLSX(w io.Writer, groups []DailyGroup, opts Options) error {
+
+	// Decide on how each day is to be laid out
+	groups = fillGaps(groups, opts)
+	layout := newDailyLayout(groups, opts)
+
+	// The daily sheet has one row per day, or per period if the days are aggregated
+	daily := &worksheet{name: "Daily", header: layout.buildHeaderRecord()}
+	if opts.GroupBy == ByDay {
+		for _, group := range groups {
+			daily.rows = append(daily.rows, layout.buildDailyCells(group))
+		}
+	} else {
+		daily.name, daily.header = periodSheetNames[opts.GroupBy], layout.buildPeriodHeaderRecord()
+		for _, period := range GroupByPeriod(groups, opts.GroupBy) {
+			daily.rows = append(daily.rows, layout.buildPeriodCells(period))
+		}
+	}
+
+	// The readings sheet has one row per reading, with no policy applied
+	readings := &worksheet{name: "Readings"}
+	layout.addHeadingSet(&readings.header, "", "")
+	for _, group := range groups {
+		for _, reading := range group.Readings {
+			readings.rows = append(readings.rows, layout.readingCells(reading))
+		}
+	}
+
+	// Package the sheets up into a workbook
+	if err := writeWorkbook(w, []*worksheet{daily, readings}); err != nil {
+		return classifiedErrorf(OutputError, "failed to write workbook to output file: %w", err)
+	}
+	return nil
+}
+
+// The names of the sheets that hold the aggregated rows of each period.
+var periodSheetNames = map[Period]string{ByDay: "Daily", ByWeek: "Weekly", ByISOWeek: "Weekly", ByMonth: "Monthly"}
+
+// writeWorkbook writes the given sheets to w as the parts of an .xlsx package.
+func writeWorkbook(w io.Writer, sheets []*worksheet) error {
+
+	// Assemble the parts that describe the workbook and its sheets
+	contentTypes := `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`
+	workbook := `<workbook xmlns="` + spreadsheetNamespace + `" xmlns:r="` + relationshipsNamespace + `"><sheets>`
+	workbookRels := `<Relationships xmlns="` + packageRelationships + `">`
+	for index, sheet := range sheets {
+		number := strconv.Itoa(index + 1)
+		contentTypes += `<Override PartName="/xl/worksheets/sheet` + number + `.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`
+		workbook += `<sheet name="` + escapeXML(sheet.name) + `" sheetId="` + number + `" r:id="rId` + number + `"/>`
+		workbookRels += `<Relationship Id="rId` + number + `" Type="` + relationshipsNamespace + `/worksheet" Target="worksheets/sheet` + number + `.xml"/>`
+	}
+	contentTypes += `</Types>`
+	workbook += `</sheets></workbook>`
+	workbookRels += `<Relationship Id="rId` + strconv.Itoa(len(sheets)+1) + `" Type="` + relationshipsNamespace + `/styles" Target="styles.xml"/></Relationships>`
+	rootRels := `<Relationships xmlns="` + packageRelationships + `">` +
+		`<Relationship Id="rId1" Type="` + relationshipsNamespace + `/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+
+	// Write the fixed parts, then each of the sheets
+	archive := zip.NewWriter(w)
+	for _, part := range []struct{ name, content string }{
+		{"[Content_Types].xml", contentTypes},
+		{"_rels/.rels", rootRels},
+		{"xl/workbook.xml", workbook},
+		{"xl/_rels/workbook.xml.rels", workbookRels},
+		{"xl/styles.xml", workbookStyles},
+	} {
+		if err := writePart(archive, part.name, func(bw *bufio.Writer) { bw.WriteString(part.content) }); err != nil {
+			return err
+		}
+	}
+	for index, sheet := range sheets {
+		name := fmt.Sprintf("xl/worksheets/sheet%d.xml", index+1)
+		if err := writePart(archive, name, sheet.write); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// writePart adds a part to the package, its XML declaration followed by whatever the
+// given function writes.
+func writePart(archive *zip.Writer, name string, content func(bw *bufio.Writer)) error {
+	part, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(part)
+	bw.WriteString(xml.Header)
+	content(bw)
+	return bw.Flush()
+}
+
+// The styles of the workbook, providing the cell formats used for headers and dates.
+const workbookStyles = `<styleSheet xmlns="` + spreadsheetNamespace + `">` +
+	`<numFmts count="2"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm:ss"/><numFmt numFmtId="165" formatCode="yyyy-mm-dd"/></numFmts>` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="4">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`<xf numFmtId="165" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`</cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`</styleSheet>`
+
+// write writes the XML of the sheet, with the header row frozen at the top.
+func (s *worksheet) write(bw *bufio.Writer) {
+
+	bw.WriteString(`<worksheet xmlns="` + spreadsheetNamespace + `">`)
+	bw.WriteString(`<sheetViews><sheetView workbookViewId="0">` +
+		`<pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/>` +
+		`</sheetView></sheetViews>`)
+
+	// Make the columns wide enough for their headings, and for dates
+	if len(s.header) > 0 {
+		bw.WriteString(`<cols>`)
+		for index, name := range s.header {
+			width := len(name) + 2
+			if s.columnHasTimes(index) && width < 20 {
+				width = 20
+			} else if width < 10 {
+				width = 10
+			}
+			fmt.Fprintf(bw, `<col min="%d" max="%d" width="%d" customWidth="1"/>`, index+1, index+1, width)
+		}
+		bw.WriteString(`</cols>`)
+	}
+
+	// Write the header row, then the data rows
+	bw.WriteString(`<sheetData>`)
+	header := make([]cell, len(s.header))
+	for index, name := range s.header {
+		header[index] = textValue(name)
+	}
+	writeRow(bw, 1, header, headerStyle)
+	for index, row := range s.rows {
+		writeRow(bw, index+2, row, defaultStyle)
+	}
+	bw.WriteString(`</sheetData></worksheet>`)
+}
+
+// columnHasTimes returns true if any of the rows has a time, or a date, in the given column.
+func (s *worksheet) columnHasTimes(column int) bool {
+	for _, row := range s.rows {
+		if column < len(row) && (row[column].kind == timeCell || row[column].kind == dateCell) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeRow writes one row of cells, skipping blank cells, with text cells in the given style.
+func writeRow(bw *bufio.Writer, number int, cells []cell, textStyle int) {
+	fmt.Fprintf(bw, `<row r="%d">`, number)
+	for index, c := range cells {
+		ref := columnName(index) + strconv.Itoa(number)
+		switch c.kind {
+		case textCell:
+			fmt.Fprintf(bw, `<c r="%s" s="%d" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, textStyle, escapeXML(c.text))
+		case numberCell:
+			fmt.Fprintf(bw, `<c r="%s"><v>%s</v></c>`, ref, c.String())
+		case timeCell:
+			fmt.Fprintf(bw, `<c r="%s" s="%d"><v>%s</v></c>`, ref, timestampStyle, strconv.FormatFloat(spreadsheetDate(c.time), 'f', -1, 64))
+		case dateCell:
+			fmt.Fprintf(bw, `<c r="%s" s="%d"><v>%s</v></c>`, ref, dateStyle, strconv.FormatFloat(spreadsheetDate(c.time), 'f', -1, 64))
+		}
+	}
+	bw.WriteString(`</row>`)
+}
+
+// columnName returns the letters that name the column with the given index, counting from zero.
+func columnName(index int) string {
+	name := ""
+	for index++; index > 0; index = (index - 1) / 26 {
+		name = string(rune('A'+(index-1)%26)) + name
+	}
+	return name
+}
+
+// spreadsheetDate returns the spreadsheet date serial number of the wall clock time of t:
+// the number of days, and fraction of a day, since the spreadsheet epoch.
+func spreadsheetDate(t time.Time) float64 {
+	year, month, day := t.Date()
+	hour, minute, second := t.Clock()
+	wallClock := time.Date(year, month, day, hour, minute, second, 0, time.UTC)
+	seconds := wallClock.Unix() - spreadsheetEpoch.Unix()
+	return float64(seconds) / (24 * 60 * 60)
+}
+
+// escapeXML returns the given text with the characters that are special to XML escaped.
+func escapeXML(text string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(text))
+	return sb.String()
+}
@@ -0,0 +1,77 @@
+package dlycsv
+
+// Unit tests for the Excel workbook output.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestConvertToXLSX converts the happy path input to a workbook and checks its content.
+func TestConvertToXLSX(t *testing.T) {
+
+	inputFile, err := os.Open("../testdata/happypath.in.csv")
+	require.Nil(t, err, "could not open input file: %v", err)
+	defer inputFile.Close()
+
+	// Convert into a buffer
+	var output bytes.Buffer
+	err = Convert(context.Background(), inputFile, &output, Options{Output: XLSXOutput})
+	require.Nil(t, err, "Convert returned an error: %v", err)
+
+	// The workbook should have all of its parts
+	parts := readWorkbookParts(t, output.Bytes())
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels",
+		"xl/styles.xml", "xl/worksheets/sheet1.xml", "xl/worksheets/sheet2.xml"} {
+		require.Contains(t, parts, name, "workbook is missing a part")
+	}
+	require.Contains(t, parts["xl/workbook.xml"], `<sheet name="Daily" sheetId="1" r:id="rId1"/>`)
+	require.Contains(t, parts["xl/workbook.xml"], `<sheet name="Readings" sheetId="2" r:id="rId2"/>`)
+
+	// The daily sheet has a header, then dates as date serial numbers and numbers as numbers
+	daily := parts["xl/worksheets/sheet1.xml"]
+	require.Contains(t, daily, `<c r="A1" s="2" t="inlineStr"><is><t xml:space="preserve">Date Time 1</t></is></c>`)
+	require.Contains(t, daily, `<row r="2"><c r="A2" s="1"><v>43947.261608796296</v></c><c r="B2"><v>97</v></c>`)
+
+	// The readings sheet has a row for each reading as well as the header
+	readings := parts["xl/worksheets/sheet2.xml"]
+	require.Contains(t, readings, `<row r="23">`)
+	require.NotContains(t, readings, `<row r="24">`)
+}
+
+// TestSpreadsheetValues confirms the conversion of column numbers and dates.
+func TestSpreadsheetValues(t *testing.T) {
+	require.Equal(t, "A", columnName(0))
+	require.Equal(t, "Z", columnName(25))
+	require.Equal(t, "AA", columnName(26))
+	require.Equal(t, "BA", columnName(52))
+	require.Equal(t, 43831.5, spreadsheetDate(time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)))
+	require.Equal(t, 43831.5, spreadsheetDate(time.Date(2020, 1, 1, 12, 0, 0, 0, time.FixedZone("PDT", -7*60*60))))
+}
+
+// readWorkbookParts unzips a workbook, returning the content of each of its parts.
+func readWorkbookParts(t *testing.T, workbook []byte) map[string]string {
+	archive, err := zip.NewReader(bytes.NewReader(workbook), int64(len(workbook)))
+	require.Nil(t, err, "output is not a zip archive: %v", err)
+	parts := make(map[string]string)
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		require.Nil(t, err, "could not open %s: %v", file.Name, err)
+		content, err := ioutil.ReadAll(reader)
+		require.Nil(t, err, "could not read %s: %v", file.Name, err)
+		reader.Close()
+		parts[file.Name] = string(content)
+	}
+	return parts
+}
@@ -0,0 +1,110 @@
+package dlycsv
+
+// The time zones of readings: the zone in which times without an offset were recorded,
+// and the zone in which readings are gathered into days and written.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseZone returns the time zone named by the given text: UTC, Local for the zone of the
+// computer, a time zone database name such as "America/New_York", or a fixed offset from UTC
+// such as "+05:30", "-0700" or "UTC+2". Named zones follow their daylight saving rules;
+// fixed offsets never change.
+func ParseZone(text string) (*time.Location, error) {
+	text = strings.TrimSpace(text)
+	switch strings.ToUpper(text) {
+	case "":
+		return nil, fmt.Errorf("time zone is blank")
+	case "UTC", "GMT", "Z":
+		return time.UTC, nil
+	case "LOCAL":
+		return time.Local, nil
+	}
+
+	// Offsets start with a sign, perhaps after UTC or GMT
+	offset := text
+	if upper := strings.ToUpper(offset); strings.HasPrefix(upper, "UTC") || strings.HasPrefix(upper, "GMT") {
+		offset = offset[3:]
+	}
+	if strings.HasPrefix(offset, "+") || strings.HasPrefix(offset, "-") {
+		seconds, err := parseZoneOffset(offset)
+		if err != nil {
+			return nil, fmt.Errorf("time zone offset %q %v", text, err)
+		}
+		return time.FixedZone(text, seconds), nil
+	}
+
+	// Anything else must be in the time zone database
+	location, err := time.LoadLocation(text)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a known time zone", text)
+	}
+	return location, nil
+}
+
+// parseZoneOffset parses a signed offset from UTC in hours, hh:mm or hhmm form, returning
+// the offset in seconds.
+func parseZoneOffset(offset string) (int, error) {
+	sign := 1
+	if offset[0] == '-' {
+		sign = -1
+	}
+	digits := strings.Replace(offset[1:], ":", "", 1)
+	hours, minutes := digits, "0"
+	if len(digits) > 2 {
+		hours, minutes = digits[:len(digits)-2], digits[len(digits)-2:]
+	}
+	hourCount, err := strconv.Atoi(hours)
+	if err != nil || hourCount < 0 || hourCount > 14 || len(hours) > 2 {
+		return 0, fmt.Errorf("does not have a valid hour")
+	}
+	minuteCount, err := strconv.Atoi(minutes)
+	if err != nil || minuteCount < 0 || minuteCount > 59 {
+		return 0, fmt.Errorf("does not have a valid minute")
+	}
+	return sign * (hourCount*60*60 + minuteCount*60), nil
+}
+
+// localize places a time read from an input in the time zones of the options. A time read
+// without an offset, which the parsers leave in UTC, has its clock reading taken to be in
+// the InputZone; a time read with its own offset keeps it. The result is then moved into
+// the DisplayZone, if the options have one.
+func (opts Options) localize(t time.Time) time.Time {
+	if opts.InputZone != nil && t.Location() == time.UTC {
+		t = wallClockIn(t, opts.InputZone)
+	}
+	if opts.DisplayZone != nil {
+		t = t.In(opts.DisplayZone)
+	}
+	return t
+}
+
+// outputZone returns the time zone in which the clock readings of the daily output are
+// written for readings read without an offset: the DisplayZone if the options have one,
+// or else the InputZone, or else UTC.
+func (opts Options) outputZone() *time.Location {
+	switch {
+	case opts.DisplayZone != nil:
+		return opts.DisplayZone
+	case opts.InputZone != nil:
+		return opts.InputZone
+	}
+	return time.UTC
+}
+
+// wallClockIn returns the time with the same date and clock reading as the given time, but
+// in the given zone. A clock reading that the zone skips when daylight saving starts, or
+// passes twice when it ends, takes whichever of the two offsets time.Date chooses.
+func wallClockIn(t time.Time, zone *time.Location) time.Time {
+	year, month, day := t.Date()
+	hour, minute, second := t.Clock()
+	return time.Date(year, month, day, hour, minute, second, t.Nanosecond(), zone)
+}
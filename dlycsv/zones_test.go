@@ -0,0 +1,159 @@
+package dlycsv
+
+// Unit tests for the time zones of readings.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// mustZone returns the named time zone, failing the test if it cannot be loaded.
+func mustZone(t *testing.T, name string) *time.Location {
+	zone, err := ParseZone(name)
+	require.Nil(t, err, "ParseZone returned an error for %q: %v", name, err)
+	return zone
+}
+
+// TestParseZone confirms the time zone names and offsets that are accepted.
+func TestParseZone(t *testing.T) {
+	for text, offset := range map[string]int{
+		"UTC":     0,
+		"+05:30":  5*60*60 + 30*60,
+		"-0700":   -7 * 60 * 60,
+		"UTC+2":   2 * 60 * 60,
+		"GMT-3":   -3 * 60 * 60,
+		"+14":     14 * 60 * 60,
+		"-09:30 ": -(9*60*60 + 30*60),
+	} {
+		zone := mustZone(t, text)
+		_, actual := time.Date(2020, 1, 1, 0, 0, 0, 0, zone).Zone()
+		require.Equal(t, offset, actual, "wrong offset for %q", text)
+	}
+	require.Equal(t, time.Local, mustZone(t, "local"))
+
+	// Named zones keep their daylight saving rules
+	newYork := mustZone(t, "America/New_York")
+	_, winter := time.Date(2020, 1, 1, 12, 0, 0, 0, newYork).Zone()
+	_, summer := time.Date(2020, 7, 1, 12, 0, 0, 0, newYork).Zone()
+	require.Equal(t, -5*60*60, winter)
+	require.Equal(t, -4*60*60, summer)
+
+	for _, text := range []string{"", "+", "+15", "-05:60", "+1:2:3", "Nowhere/Land", "EST+x"} {
+		_, err := ParseZone(text)
+		require.NotNil(t, err, "%q should have been refused", text)
+	}
+}
+
+// TestZones confirms that the input zone gives times without offsets their zone, and that
+// the display zone decides the day on which each reading falls.
+func TestZones(t *testing.T) {
+	input := "Date,Time,SYS,DIA,Pulse\n" +
+		"2020-03-07,22:30,120,80,60\n" +
+		"2020-03-08,08:00,121,81,61\n" +
+		"2020-03-08,23:15,122,82,62\n"
+	format, err := NewGenericFormat("date=Date,time=Time,systolic=SYS,diastolic=DIA,pulse=Pulse")
+	require.Nil(t, err, "NewGenericFormat returned an error: %v", err)
+
+	// By default the clock readings are kept as they are, in UTC
+	readings, err := ParseReadings(context.Background(), strings.NewReader(input), Options{Format: format})
+	require.Nil(t, err, "ParseReadings returned an error: %v", err)
+	require.Equal(t, time.UTC, readings[0].Time.Location())
+	require.Len(t, GroupByDay(readings), 2)
+
+	// In New York, daylight saving started in between the first two readings
+	newYork := mustZone(t, "America/New_York")
+	opts := Options{Format: format, InputZone: newYork}
+	readings, err = ParseReadings(context.Background(), strings.NewReader(input), opts)
+	require.Nil(t, err, "ParseReadings returned an error: %v", err)
+	require.Equal(t, time.Date(2020, 3, 8, 3, 30, 0, 0, time.UTC), readings[0].Time.UTC())
+	require.Equal(t, time.Date(2020, 3, 8, 12, 0, 0, 0, time.UTC), readings[1].Time.UTC())
+
+	// Seen from London, the late evening readings fall on the following days
+	opts.DisplayZone = mustZone(t, "Europe/London")
+	readings, err = ParseReadings(context.Background(), strings.NewReader(input), opts)
+	require.Nil(t, err, "ParseReadings returned an error: %v", err)
+	groups := GroupByDay(readings)
+	require.Len(t, groups, 2)
+	require.Equal(t, "2020-03-08", groups[0].Date.Format(dateLayout))
+	require.Len(t, groups[0].Readings, 2)
+	require.Equal(t, "2020-03-09", groups[1].Date.Format(dateLayout))
+	require.Equal(t, "2020-03-09 03:15:00", groups[1].Readings[0].Time.Format(timestampLayout))
+
+	// The date range is applied to the days of the display zone
+	opts.From = time.Date(2020, 3, 9, 0, 0, 0, 0, time.UTC)
+	readings, err = ParseReadings(context.Background(), strings.NewReader(input), opts)
+	require.Nil(t, err, "ParseReadings returned an error: %v", err)
+	require.Len(t, readings, 1)
+}
+
+// TestReadingZones confirms that offsets and zones recorded with each reading are kept.
+func TestReadingZones(t *testing.T) {
+	input := "When,Zone,SYS,DIA\n" +
+		"2020-05-01 23:30,+09:00,120,80\n" +
+		"2020-05-01 21:00,America/Chicago,121,81\n" +
+		"2020-05-01T22:00:00Z,-05:00,122,82\n" +
+		"2020-05-02 07:00,,123,83\n"
+	format, err := NewGenericFormat("datetime=When,zone=Zone,systolic=SYS,diastolic=DIA")
+	require.Nil(t, err, "NewGenericFormat returned an error: %v", err)
+
+	// Each reading falls on the day of its own zone, the last in the input zone; an
+	// explicit offset wins over the zone column and the input zone
+	opts := Options{Format: format, InputZone: mustZone(t, "+01:00")}
+	readings, err := ParseReadings(context.Background(), strings.NewReader(input), opts)
+	require.Nil(t, err, "ParseReadings returned an error: %v", err)
+	require.Len(t, readings, 4)
+	require.Equal(t, time.Date(2020, 5, 1, 14, 30, 0, 0, time.UTC), readings[0].Time.UTC())
+	require.Equal(t, time.Date(2020, 5, 2, 2, 0, 0, 0, time.UTC), readings[1].Time.UTC())
+	require.Equal(t, time.Date(2020, 5, 1, 22, 0, 0, 0, time.UTC), readings[2].Time.UTC())
+	require.Equal(t, time.Date(2020, 5, 2, 6, 0, 0, 0, time.UTC), readings[3].Time.UTC())
+	groups := GroupByDay(readings)
+	require.Len(t, groups, 2, "readings in different zones on the same date share a day")
+	require.Len(t, groups[0].Readings, 3)
+
+	// A zone that cannot be understood rejects the reading
+	var rejects []Reject
+	opts.Rejected = func(reject Reject) { rejects = append(rejects, reject) }
+	_, err = ParseReadings(context.Background(), strings.NewReader("When,Zone,SYS,DIA\n2020-05-01 23:30,Mars,120,80\n"), opts)
+	require.Nil(t, err, "ParseReadings returned an error: %v", err)
+	require.Len(t, rejects, 1)
+	require.Contains(t, rejects[0].Reason, "Mars")
+}
+
+// TestZonesRoundTrip confirms that a daily file written in the display zone is read back
+// in it, so that its readings are the same instants.
+func TestZonesRoundTrip(t *testing.T) {
+	tokyo := mustZone(t, "Asia/Tokyo")
+	readings := []Reading{
+		{Time: time.Date(2020, 5, 1, 14, 30, 0, 0, time.UTC), Systolic: 120, Diastolic: 80},
+		{Time: time.Date(2020, 5, 1, 16, 0, 0, 0, time.UTC), Systolic: 121, Diastolic: 81},
+	}
+	for index := range readings {
+		readings[index].Time = readings[index].Time.In(tokyo)
+	}
+	var output bytes.Buffer
+	opts := Options{DisplayZone: tokyo}
+	require.Nil(t, WriteDaily(&output, GroupByDay(readings), opts))
+	require.Equal(t, "Date Time 1,Systolic 1,Diastolic 1,Pulse 1,Note 1\n"+
+		"2020-05-01 23:30:00,120,80,,\n2020-05-02 01:00:00,121,81,,\n", output.String(), "the readings should fall on two days")
+
+	// Dates given for the calendar are dates of the display zone
+	gaps := FindGaps(GroupByDay(readings), time.Date(2020, 4, 30, 0, 0, 0, 0, time.UTC), time.Time{})
+	require.Len(t, gaps, 1)
+	require.Equal(t, 1, gaps[0].Days)
+	require.Equal(t, "2020-04-30", gaps[0].From.Format(dateLayout))
+
+	read, err := ReadDaily(context.Background(), &output, opts)
+	require.Nil(t, err, "ReadDaily returned an error: %v", err)
+	require.Len(t, read, 2)
+	require.True(t, read[0].Time.Equal(readings[0].Time), "%v is not %v", read[0].Time, readings[0].Time)
+	require.True(t, read[1].Time.Equal(readings[1].Time), "%v is not %v", read[1].Time, readings[1].Time)
+}
@@ -0,0 +1,400 @@
+package main
+
+// Command line flag values that parse themselves into dlycsv option types.
+//
+// Copyright © 2020 Michael D Broadway <mikebway@mikebway.com>
+//
+// Licensed under the ISC License (ISC)
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikebway/bpdaily/dlycsv"
+)
+
+// slotsValue is a flag.Value that parses a list of named time slots.
+type slotsValue struct {
+	slots *[]dlycsv.Slot // Where the parsed slots are stored
+}
+
+// String returns the slots in the form that Set accepts.
+func (v *slotsValue) String() string {
+	if v.slots == nil {
+		return ""
+	}
+	var items []string
+	for _, slot := range *v.slots {
+		items = append(items, slot.String())
+	}
+	return strings.Join(items, ",")
+}
+
+// Set parses the given slot list.
+func (v *slotsValue) Set(spec string) error {
+	slots, err := dlycsv.ParseSlots(spec)
+	if err != nil {
+		return err
+	}
+	*v.slots = slots
+	return nil
+}
+
+// policyValue is a flag.Value that parses a duplicate resolution policy name.
+type policyValue struct {
+	policy *dlycsv.Policy // Where the parsed policy is stored
+}
+
+// String returns the name of the policy.
+func (v *policyValue) String() string {
+	if v.policy == nil {
+		return dlycsv.KeepAll.String()
+	}
+	return v.policy.String()
+}
+
+// Set parses the given policy name.
+func (v *policyValue) Set(name string) error {
+	policy, err := dlycsv.ParsePolicy(name)
+	if err != nil {
+		return err
+	}
+	*v.policy = policy
+	return nil
+}
+
+// periodValue is a flag.Value that parses the name of an aggregation period.
+type periodValue struct {
+	period *dlycsv.Period // Where the parsed period is stored
+}
+
+// String returns the name of the period.
+func (v *periodValue) String() string {
+	if v.period == nil {
+		return dlycsv.ByDay.String()
+	}
+	return v.period.String()
+}
+
+// Set parses the given period name.
+func (v *periodValue) Set(name string) error {
+	period, err := dlycsv.ParsePeriod(name)
+	if err != nil {
+		return err
+	}
+	*v.period = period
+	return nil
+}
+
+// formatValue is a flag.Value that selects a registered input format by name, or
+// format detection with the name auto.
+type formatValue struct {
+	format *dlycsv.InputFormat // Where the selected format is stored, nil for detection
+}
+
+// String returns the name of the selected format.
+func (v *formatValue) String() string {
+	if v.format == nil || *v.format == nil {
+		return "auto"
+	}
+	return (*v.format).Name()
+}
+
+// Set selects the format with the given name.
+func (v *formatValue) Set(name string) error {
+	if strings.EqualFold(name, "auto") {
+		*v.format = nil
+		return nil
+	}
+	format, err := dlycsv.LookupFormat(name)
+	if err != nil {
+		return err
+	}
+	*v.format = format
+	return nil
+}
+
+// columnsValue is a flag.Value that selects a generic input format described by
+// the user.
+type columnsValue struct {
+	format *dlycsv.InputFormat // Where the generic format is stored
+}
+
+// String returns nothing since the description is not retained.
+func (v *columnsValue) String() string {
+	return ""
+}
+
+// Set builds a generic format from the given description.
+func (v *columnsValue) Set(spec string) error {
+	format, err := dlycsv.NewGenericFormat(spec)
+	if err != nil {
+		return err
+	}
+	*v.format = format
+	return nil
+}
+
+// formatNames returns the names of the registered input formats as a comma separated list.
+func formatNames() string {
+	var names []string
+	for _, format := range dlycsv.Formats() {
+		names = append(names, format.Name())
+	}
+	return strings.Join(names, ", ")
+}
+
+// outputFormatValue is a flag.Value that parses an output format name.
+type outputFormatValue struct {
+	format *dlycsv.OutputFormat // Where the parsed output format is stored
+}
+
+// String returns the name of the output format.
+func (v *outputFormatValue) String() string {
+	if v.format == nil {
+		return dlycsv.AutoOutput.String()
+	}
+	return v.format.String()
+}
+
+// Set parses the given output format name.
+func (v *outputFormatValue) Set(name string) error {
+	format, err := dlycsv.ParseOutputFormat(name)
+	if err != nil {
+		return err
+	}
+	*v.format = format
+	return nil
+}
+
+// guidelineValue is a flag.Value that parses a blood pressure guideline name.
+type guidelineValue struct {
+	guideline **dlycsv.Guideline // Where the parsed guideline is stored
+}
+
+// String returns the name of the guideline.
+func (v *guidelineValue) String() string {
+	if v.guideline == nil || *v.guideline == nil {
+		return dlycsv.AHA2017.String()
+	}
+	return (*v.guideline).String()
+}
+
+// Set parses the given guideline name.
+func (v *guidelineValue) Set(name string) error {
+	guideline, err := dlycsv.ParseGuideline(name)
+	if err != nil {
+		return err
+	}
+	*v.guideline = guideline
+	return nil
+}
+
+// plausibilityValue is a flag.Value that parses a plausibility action name.
+type plausibilityValue struct {
+	plausibility *dlycsv.Plausibility // Where the parsed action is stored
+}
+
+// String returns the name of the plausibility action.
+func (v *plausibilityValue) String() string {
+	if v.plausibility == nil {
+		return dlycsv.FlagImplausible.String()
+	}
+	return v.plausibility.String()
+}
+
+// Set parses the given plausibility action name.
+func (v *plausibilityValue) Set(name string) error {
+	plausibility, err := dlycsv.ParsePlausibility(name)
+	if err != nil {
+		return err
+	}
+	*v.plausibility = plausibility
+	return nil
+}
+
+// limitsValue is a flag.Value that parses the plausible ranges of reading values.
+type limitsValue struct {
+	limits **dlycsv.Limits // Where the parsed limits are stored
+}
+
+// String returns the limits in the form that Set accepts.
+func (v *limitsValue) String() string {
+	if v.limits == nil || *v.limits == nil {
+		return dlycsv.DefaultLimits.String()
+	}
+	return (*v.limits).String()
+}
+
+// Set parses the given list of limits.
+func (v *limitsValue) Set(spec string) error {
+	limits, err := dlycsv.ParseLimits(spec)
+	if err != nil {
+		return err
+	}
+	*v.limits = &limits
+	return nil
+}
+
+// pathsValue is a flag.Value that collects a file path each time the flag is given.
+type pathsValue struct {
+	paths *[]string // Where the paths are collected
+}
+
+// String returns the paths collected so far, separated by commas.
+func (v *pathsValue) String() string {
+	if v.paths == nil {
+		return ""
+	}
+	return strings.Join(*v.paths, ",")
+}
+
+// Set adds another path.
+func (v *pathsValue) Set(path string) error {
+	*v.paths = append(*v.paths, path)
+	return nil
+}
+
+// dateValue is a flag.Value that parses a date in the form yyyy-mm-dd.
+type dateValue struct {
+	date *time.Time // Where the parsed date is stored
+}
+
+// String returns the date in the form that Set accepts, or nothing if there is no date.
+func (v *dateValue) String() string {
+	if v.date == nil || v.date.IsZero() {
+		return ""
+	}
+	return v.date.Format("2006-01-02")
+}
+
+// Set parses the given date.
+func (v *dateValue) Set(text string) error {
+	date, err := time.Parse("2006-01-02", text)
+	if err != nil {
+		return fmt.Errorf("%q is not a date in the form yyyy-mm-dd", text)
+	}
+	*v.date = date
+	return nil
+}
+
+// lastValue is a flag.Value that parses the length of a period ending today into the
+// first day of that period.
+type lastValue struct {
+	from *time.Time // Where the first day of the period is stored
+}
+
+// String returns nothing, since the length of the period is not kept.
+func (v *lastValue) String() string {
+	return ""
+}
+
+// Set parses the given period length, counting back from today's date.
+func (v *lastValue) Set(length string) error {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	from, err := dlycsv.LastPeriod(length, today)
+	if err != nil {
+		return err
+	}
+	*v.from = from
+	return nil
+}
+
+// zoneValue is a flag.Value that parses a time zone name or offset.
+type zoneValue struct {
+	zone **time.Location // Where the parsed time zone is stored
+}
+
+// String returns the name of the time zone, or nothing if there is none.
+func (v *zoneValue) String() string {
+	if v.zone == nil || *v.zone == nil {
+		return ""
+	}
+	return (*v.zone).String()
+}
+
+// Set parses the given time zone.
+func (v *zoneValue) Set(text string) error {
+	zone, err := dlycsv.ParseZone(text)
+	if err != nil {
+		return err
+	}
+	*v.zone = zone
+	return nil
+}
+
+// dayStartValue is a flag.Value that parses the time of day at which each day starts.
+type dayStartValue struct {
+	dayStart *time.Duration // Where the parsed time of day is stored
+}
+
+// String returns the time of day in hh:mm form.
+func (v *dayStartValue) String() string {
+	if v.dayStart == nil {
+		return "00:00"
+	}
+	return fmt.Sprintf("%02d:%02d", int(*v.dayStart/time.Hour), int(*v.dayStart%time.Hour/time.Minute))
+}
+
+// Set parses the given time of day.
+func (v *dayStartValue) Set(text string) error {
+	dayStart, err := dlycsv.ParseDayStart(text)
+	if err != nil {
+		return err
+	}
+	*v.dayStart = dayStart
+	return nil
+}
+
+// rollingValue is a flag.Value that parses a list of rolling average window lengths.
+type rollingValue struct {
+	windows *[]int // Where the parsed window lengths are stored
+}
+
+// String returns the window lengths in the form that Set accepts.
+func (v *rollingValue) String() string {
+	if v.windows == nil {
+		return ""
+	}
+	var lengths []string
+	for _, days := range *v.windows {
+		lengths = append(lengths, strconv.Itoa(days))
+	}
+	return strings.Join(lengths, ",")
+}
+
+// Set parses the given window lengths.
+func (v *rollingValue) Set(spec string) error {
+	windows, err := dlycsv.ParseRollingWindows(spec)
+	if err != nil {
+		return err
+	}
+	*v.windows = windows
+	return nil
+}
+
+// daysValue is a flag.Value that parses a number of days, which must be at least one.
+type daysValue struct {
+	days *int // Where the parsed number of days is stored
+}
+
+// String returns the number of days in the form that Set accepts.
+func (v *daysValue) String() string {
+	if v.days == nil {
+		return ""
+	}
+	return strconv.Itoa(*v.days)
+}
+
+// Set parses the given number of days.
+func (v *daysValue) Set(text string) error {
+	days, err := strconv.Atoi(strings.TrimSpace(text))
+	if err != nil || days < 1 {
+		return fmt.Errorf("%q is not a whole number of days of at least one", text)
+	}
+	*v.days = days
+	return nil
+}
@@ -10,44 +10,78 @@ package main
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/mikebway/bpdaily/dlycsv"
 )
 
-// WARNING: THIS IS A VERY CRUDE IMPLEMENTATION. NO OPTIONS. NO FINESSE.
+// The version reported by the --version option. This may be overridden at build time with:
+//
+//	go build -ldflags "-X main.version=1.2.3"
+var version = "1.1.0"
+
+// The process exit codes, one for each class of failure.
+const (
+	exitSuccess = 0 // All went well
+	exitFailure = 1 // A failure that could not be classified
+	exitUsage   = 2 // The command line arguments were not valid, or conflict with the input
+	exitInput   = 3 // The input could not be opened or read
+	exitFormat  = 4 // The input content was not acceptable
+	exitOutput  = 5 // The output could not be created or written
+)
 
 var (
 	unitTesting  = false // True if unit testing and NOT to os.Exit from the main function
 	executeError error   // The error value obtained by Execute(), captured for unit test purposes
+	exitCode     int     // The exit code derived from executeError, captured for unit test purposes
+
+	stdout io.Writer = os.Stdout // Where command results are written, replaced when unit testing
+	stderr io.Writer = os.Stderr // Where errors and "-" rejects reports go, replaced when unit testing
 )
 
 // Command line entry point.
 func main() {
 
-	// There must be two arguments!
-	if len(os.Args) == 3 {
-
-		// Translate the input CSV file into the output CSV file
-		// but don't overwrite the output file if it already exists
-		executeError = dlycsv.ConvertBloodPressureCSVToDaily(os.Args[1], os.Args[2], false)
-
-	} else {
-		executeError = errors.New(`
-Usage: 
-
-  bpdaily input-file-path.csv output-file-path
-	
-`)
-	}
+	// Run whichever command the arguments ask for
+	executeError = execute(os.Args[1:])
 
-	// Display any error that occurred
+	// Display any error that occurred, on standard error so as not to
+	// pollute any output written to standard output
+	exitCode = exitCodeFor(executeError)
 	if executeError != nil {
-		fmt.Printf("ERROR - %v\n", executeError.Error())
+		fmt.Fprintf(stderr, "ERROR - %v\n", executeError.Error())
 
 		// Do not exit if we are unit testing
 		if !unitTesting {
-			os.Exit(1)
+			os.Exit(exitCode)
 		}
 	}
 }
+
+// exitCodeFor returns the process exit code that matches the class of the given error.
+func exitCodeFor(err error) int {
+
+	// No error, no problem
+	if err == nil {
+		return exitSuccess
+	}
+
+	// Usage errors are ours, the rest, including options that conflict with the input, are
+	// classified by the dlycsv package
+	var usage *usageError
+	if errors.As(err, &usage) {
+		return exitUsage
+	}
+	switch dlycsv.ClassOf(err) {
+	case dlycsv.OptionsError:
+		return exitUsage
+	case dlycsv.InputError:
+		return exitInput
+	case dlycsv.FormatError:
+		return exitFormat
+	case dlycsv.OutputError:
+		return exitOutput
+	}
+	return exitFailure
+}
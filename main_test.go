@@ -7,7 +7,11 @@ package main
 // Licensed under the ISC License (ISC)
 
 import (
+	"bytes"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -18,27 +22,96 @@ import (
 func beforeEach() {
 	unitTesting = true
 	executeError = nil
+	exitCode = exitSuccess
+	stdout = os.Stdout
+	stderr = os.Stderr
 }
 
-// TestTooFewParameters checks that the program will object if less than two parameters
-// are provided.
-func TestTooFewParameters(t *testing.T) {
+// redirectedStdout replaces os.Stdout with a temporary file for the duration of a test.
+type redirectedStdout struct {
+	t     *testing.T // The test that redirected standard output
+	file  *os.File   // The temporary file that has replaced standard output
+	saved *os.File   // The original standard output
+}
+
+// redirectStdout replaces os.Stdout with a temporary file; call restore to put it back.
+func redirectStdout(t *testing.T) *redirectedStdout {
+	file, err := ioutil.TempFile("", "bpdaily-stdout")
+	require.Nil(t, err, "could not create temporary file: %v", err)
+	redirected := &redirectedStdout{t: t, file: file, saved: os.Stdout}
+	os.Stdout = file
+	return redirected
+}
+
+// contents returns everything written to the redirected standard output so far.
+func (r *redirectedStdout) contents() string {
+	content, err := ioutil.ReadFile(r.file.Name())
+	require.Nil(r.t, err, "could not read redirected standard output: %v", err)
+	return string(content)
+}
+
+// restore puts the original standard output back and removes the temporary file.
+func (r *redirectedStdout) restore() {
+	os.Stdout = r.saved
+	r.file.Close()
+	os.Remove(r.file.Name())
+}
+
+// captureStdout directs command results to a buffer that the caller can inspect.
+func captureStdout() *bytes.Buffer {
+	buffer := &bytes.Buffer{}
+	stdout = buffer
+	return buffer
+}
+
+// TestOmittedOutputPath checks that the daily output is written to standard output
+// if only the input file path is provided.
+func TestOmittedOutputPath(t *testing.T) {
 
 	// Make sure the main() function does not exit altogether
 	beforeEach()
+	output := redirectStdout(t)
+	defer output.restore()
 
 	// Replace the argument list with one of our own with only a single file parameter (and a program name)
 	os.Args = []string{
-		"TestTooFewParameters",      // Our fake program name
-		"./ThereIsNo/InputFile.csv", // An input file that does not exist
+		"TestOmittedOutputPath",     // Our fake program name
+		"testdata/happypath.in.csv", // The input file
 	}
 
 	// Run the program
 	main()
 
-	// There should be an error reporting an invalid parameter count
-	require.NotNil(t, executeError, "should have failed for too few parameters")
-	require.Contains(t, executeError.Error(), "bpdaily input-file-path.csv output-file-path")
+	// The daily output should have been written to standard output
+	require.Nil(t, executeError, "should have succeeded: %v", executeError)
+	expected, _ := ioutil.ReadFile("testdata/happypath.expected.csv")
+	require.Equal(t, string(expected), output.contents(), "standard output did not match expected")
+}
+
+// TestStdinToStdout checks that "-" file paths select standard input and output.
+func TestStdinToStdout(t *testing.T) {
+
+	// Make sure the main() function does not exit altogether
+	beforeEach()
+	output := redirectStdout(t)
+	defer output.restore()
+
+	// Feed the happy path file to standard input
+	inputFile, err := os.Open("testdata/happypath.in.csv")
+	require.Nil(t, err, "could not open input file: %v", err)
+	defer inputFile.Close()
+	savedStdin := os.Stdin
+	os.Stdin = inputFile
+	defer func() { os.Stdin = savedStdin }()
+
+	// Run the program
+	os.Args = []string{"TestStdinToStdout", "daily", "-", "-"}
+	main()
+
+	// The daily output should have been written to standard output
+	require.Nil(t, executeError, "should have succeeded: %v", executeError)
+	expected, _ := ioutil.ReadFile("testdata/happypath.expected.csv")
+	require.Equal(t, string(expected), output.contents(), "standard output did not match expected")
 }
 
 // TestTooManyParameters checks that the program will object if more than two parameters
@@ -62,6 +135,7 @@ func TestTooManyParameters(t *testing.T) {
 	// There should be an error reporting an invalid parameter count
 	require.NotNil(t, executeError, "should have failed for too many parameters")
 	require.Contains(t, executeError.Error(), "bpdaily input-file-path.csv output-file-path")
+	require.Equal(t, exitUsage, exitCode, "exit code should report a usage failure")
 }
 
 // TestMissingInputFile checks that the program will object if the input file
@@ -84,4 +158,602 @@ func TestMissingInputFile(t *testing.T) {
 	// There should be an error reporting an invalid parameter count
 	require.NotNil(t, executeError, "should have failed for input file not found")
 	require.Contains(t, executeError.Error(), "could not open input file")
+	require.Equal(t, exitInput, exitCode, "exit code should report an input failure")
+}
+
+// TestUsageExitCode checks that argument errors are reported with the usage exit code.
+func TestUsageExitCode(t *testing.T) {
+
+	// Make sure the main() function does not exit altogether
+	beforeEach()
+
+	// Ask for a flag that does not exist
+	os.Args = []string{"TestUsageExitCode", "--no-such-flag", "in.csv", "out.csv"}
+	main()
+
+	// There should be a usage error
+	require.NotNil(t, executeError, "should have failed for an unknown flag")
+	require.Contains(t, executeError.Error(), "flag provided but not defined")
+	require.Equal(t, exitUsage, exitCode, "exit code should report a usage failure")
+}
+
+// TestHelp checks that --help displays the usage text without error.
+func TestHelp(t *testing.T) {
+
+	// Make sure the main() function does not exit altogether
+	beforeEach()
+	output := captureStdout()
+
+	// Ask for help with the daily command
+	os.Args = []string{"TestHelp", "daily", "--help"}
+	main()
+
+	// The usage should have been displayed, including the daily flags
+	require.Nil(t, executeError, "help should not be an error")
+	require.Contains(t, output.String(), "bpdaily daily [options]")
+	require.Contains(t, output.String(), "--overwrite")
+}
+
+// TestVersion checks that --version displays the version.
+func TestVersion(t *testing.T) {
+
+	// Make sure the main() function does not exit altogether
+	beforeEach()
+	output := captureStdout()
+
+	// Ask for the version
+	os.Args = []string{"TestVersion", "--version"}
+	main()
+
+	// The version should have been displayed
+	require.Nil(t, executeError, "version should not be an error")
+	require.Equal(t, "bpdaily version "+version+"\n", output.String())
+}
+
+// TestOverwriteFlag checks that the --overwrite flag allows an existing output file
+// to be replaced, and that its absence does not.
+func TestOverwriteFlag(t *testing.T) {
+
+	// Make sure the main() function does not exit altogether
+	beforeEach()
+
+	// Create an output file that already exists
+	dir, err := ioutil.TempDir("", "bpdaily")
+	require.Nil(t, err, "could not create temporary directory: %v", err)
+	defer os.RemoveAll(dir)
+	outputPath := filepath.Join(dir, "daily.csv")
+	err = ioutil.WriteFile(outputPath, []byte("old content"), 0644)
+	require.Nil(t, err, "could not create output file: %v", err)
+
+	// Without the flag, the existing file should be left alone
+	os.Args = []string{"TestOverwriteFlag", "testdata/happypath.in.csv", outputPath}
+	main()
+	require.NotNil(t, executeError, "should have failed because the output file exists")
+	require.Equal(t, exitOutput, exitCode, "exit code should report an output failure")
+
+	// With the flag, after the file paths, the file should be replaced
+	beforeEach()
+	os.Args = []string{"TestOverwriteFlag", "daily", "testdata/happypath.in.csv", outputPath, "--overwrite"}
+	main()
+	require.Nil(t, executeError, "should have overwritten the output file: %v", executeError)
+	actual, _ := ioutil.ReadFile(outputPath)
+	expected, _ := ioutil.ReadFile("testdata/happypath.expected.csv")
+	require.Equal(t, string(expected), string(actual), "output file should have been replaced")
+}
+
+// TestBadHeaderExitCode checks that unacceptable input content is reported with
+// the format exit code.
+func TestBadHeaderExitCode(t *testing.T) {
+
+	// Make sure the main() function does not exit altogether
+	beforeEach()
+
+	// Validate a file with the wrong column names
+	os.Args = []string{"TestBadHeaderExitCode", "validate", "testdata/badheader.in.csv"}
+	main()
+
+	// The failure should be classed as a format error
+	require.NotNil(t, executeError, "should have failed for a bad header")
+	require.Equal(t, exitFormat, exitCode, "exit code should report a format failure")
+}
+
+// TestStatsCommand checks that the stats command summarizes the input file.
+func TestStatsCommand(t *testing.T) {
+
+	// Make sure the main() function does not exit altogether
+	beforeEach()
+	output := captureStdout()
+
+	// Summarize the happy path file
+	os.Args = []string{"TestStatsCommand", "stats", "testdata/happypath.in.csv"}
+	main()
+
+	// Check some of what was displayed
+	require.Nil(t, executeError, "stats should have succeeded: %v", executeError)
+	require.Contains(t, output.String(), "Readings:  22 over 14 days")
+	require.Contains(t, output.String(), "First:     2020-04-26 06:16:43")
+}
+
+// TestValidateCommand checks that the validate command reports on a messy but legal file.
+func TestValidateCommand(t *testing.T) {
+
+	// Make sure the main() function does not exit altogether
+	beforeEach()
+	output := captureStdout()
+
+	// Validate the happy path file
+	os.Args = []string{"TestValidateCommand", "validate", "testdata/happypath.in.csv"}
+	main()
+
+	// The invalid lines should have been counted
+	require.Nil(t, executeError, "validate should have succeeded: %v", executeError)
+	require.Contains(t, output.String(), "22 readings over 14 days, 2 records ignored")
+}
+
+// TestInvalidSlots checks that an invalid --slots value is reported as a usage error.
+func TestInvalidSlots(t *testing.T) {
+
+	// Make sure the main() function does not exit altogether
+	beforeEach()
+
+	// Give a slot without a time range
+	os.Args = []string{"TestInvalidSlots", "daily", "--slots", "Morning", "testdata/happypath.in.csv", "-"}
+	main()
+
+	// There should be a usage error
+	require.NotNil(t, executeError, "should have failed for an invalid slot")
+	require.Contains(t, executeError.Error(), "not in the form name=hh:mm-hh:mm")
+	require.Equal(t, exitUsage, exitCode, "exit code should report a usage failure")
+}
+
+// TestFormatFlag checks that an explicitly selected input format must match the input
+// file and that unknown formats are usage errors.
+func TestFormatFlag(t *testing.T) {
+
+	// Make sure the main() function does not exit altogether
+	beforeEach()
+
+	// The happy path file is not a Withings export
+	os.Args = []string{"TestFormatFlag", "validate", "--format", "withings", "testdata/happypath.in.csv"}
+	main()
+	require.NotNil(t, executeError, "should have failed for the wrong format")
+	require.Equal(t, exitFormat, exitCode, "exit code should report a format failure")
+
+	// But it is an Omron export
+	beforeEach()
+	output := captureStdout()
+	os.Args = []string{"TestFormatFlag", "validate", "--format", "omron", "testdata/happypath.in.csv"}
+	main()
+	require.Nil(t, executeError, "validate should have succeeded: %v", executeError)
+	require.Contains(t, output.String(), "22 readings over 14 days")
+
+	// And there is no such format as nonesuch
+	beforeEach()
+	os.Args = []string{"TestFormatFlag", "validate", "--format", "nonesuch", "testdata/happypath.in.csv"}
+	main()
+	require.NotNil(t, executeError, "should have failed for an unknown format")
+	require.Equal(t, exitUsage, exitCode, "exit code should report a usage failure")
+}
+
+// TestColumnsFlag checks that a generic input file can be described on the command line.
+func TestColumnsFlag(t *testing.T) {
+
+	// Make sure the main() function does not exit altogether
+	beforeEach()
+	output := captureStdout()
+
+	// Describe the happy path file as if it were of an unknown format
+	os.Args = []string{"TestColumnsFlag", "stats", "--columns", "datetime=Date Time,systolic=Systolic,diastolic=Diastolic", "testdata/happypath.in.csv"}
+	main()
+	require.Nil(t, executeError, "stats should have succeeded: %v", executeError)
+	require.Contains(t, output.String(), "Readings:  22 over 14 days")
+
+	// Naming a format as well is a usage error, since only one of them could be used
+	beforeEach()
+	os.Args = []string{"TestColumnsFlag", "stats", "--format", "omron", "--columns", "datetime=Date Time,systolic=Systolic,diastolic=Diastolic", "testdata/happypath.in.csv"}
+	main()
+	require.Equal(t, exitUsage, exitCode, "--format and --columns together should be a usage error")
+}
+
+// TestOutputFormat checks that the output format follows the output file extension
+// unless the --output-format flag says otherwise.
+func TestOutputFormat(t *testing.T) {
+
+	// Make sure the main() function does not exit altogether
+	beforeEach()
+
+	dir, err := ioutil.TempDir("", "bpdaily")
+	require.Nil(t, err, "could not create temporary directory: %v", err)
+	defer os.RemoveAll(dir)
+
+	// An .xlsx extension selects a workbook, which is a zip archive
+	outputPath := filepath.Join(dir, "daily.xlsx")
+	os.Args = []string{"TestOutputFormat", "testdata/happypath.in.csv", outputPath}
+	main()
+	require.Nil(t, executeError, "should have written the workbook: %v", executeError)
+	actual, _ := ioutil.ReadFile(outputPath)
+	require.True(t, bytes.HasPrefix(actual, []byte("PK")), "output should be a zip archive")
+
+	// The flag can insist on CSV
+	beforeEach()
+	os.Args = []string{"TestOutputFormat", "--output-format", "csv", "--overwrite", "testdata/happypath.in.csv", outputPath}
+	main()
+	require.Nil(t, executeError, "should have written CSV: %v", executeError)
+	actual, _ = ioutil.ReadFile(outputPath)
+	expected, _ := ioutil.ReadFile("testdata/happypath.expected.csv")
+	require.Equal(t, string(expected), string(actual), "output file should be CSV")
+}
+
+// TestChartCommand checks that the chart command writes an SVG chart whatever the
+// output file is called.
+func TestChartCommand(t *testing.T) {
+
+	// Make sure the main() function does not exit altogether
+	beforeEach()
+	redirected := redirectStdout(t)
+	defer redirected.restore()
+
+	// Omit the output path so that the chart goes to standard output
+	os.Args = []string{"TestChartCommand", "chart", "testdata/happypath.in.csv"}
+	main()
+	require.Nil(t, executeError, "chart should have succeeded: %v", executeError)
+	require.Contains(t, redirected.contents(), "<svg")
+}
+
+// TestStatsFlag checks that the --stats flag appends the statistics columns.
+func TestStatsFlag(t *testing.T) {
+
+	// Make sure the main() function does not exit altogether
+	beforeEach()
+	redirected := redirectStdout(t)
+	defer redirected.restore()
+
+	os.Args = []string{"TestStatsFlag", "daily", "--stats", "testdata/happypath.in.csv"}
+	main()
+	require.Nil(t, executeError, "daily should have succeeded: %v", executeError)
+	require.Contains(t, redirected.contents(), ",Mean Systolic,Min Systolic,Max Systolic,")
+	require.Contains(t, redirected.contents(), ",97.7,94,101,74,66,79,60,3,23.7,81.9\n")
+}
+
+// TestClassifyFlags checks that the --classify and --guideline flags add category columns
+// and that the stats command names the category of the mean pressures.
+func TestClassifyFlags(t *testing.T) {
+
+	// Make sure the main() function does not exit altogether
+	beforeEach()
+	redirected := redirectStdout(t)
+	defer redirected.restore()
+
+	os.Args = []string{"TestClassifyFlags", "daily", "--classify", "--guideline", "esh2023", "testdata/happypath.in.csv"}
+	main()
+	require.Nil(t, executeError, "daily should have succeeded: %v", executeError)
+	require.Contains(t, redirected.contents(), ",Category 1,")
+	require.Contains(t, redirected.contents(), ",Daily Category\n")
+
+	// An unknown guideline is a usage error
+	beforeEach()
+	os.Args = []string{"TestClassifyFlags", "daily", "--classify", "--guideline", "jnc7", "testdata/happypath.in.csv"}
+	main()
+	require.Equal(t, exitUsage, exitCode, "an unknown guideline should be a usage error")
+
+	// The stats command reports the category of the means
+	beforeEach()
+	output := captureStdout()
+	os.Args = []string{"TestClassifyFlags", "stats", "testdata/happypath.in.csv"}
+	main()
+	require.Nil(t, executeError, "stats should have succeeded: %v", executeError)
+	require.Contains(t, output.String(), "Category:  Normal (aha2017)")
+}
+
+// TestLenientFlag checks that the --lenient flag skips records with the wrong number of
+// fields and that the --rejects flag reports every record that was dropped.
+func TestLenientFlag(t *testing.T) {
+
+	// Make sure the main() function does not exit altogether
+	beforeEach()
+	dir, err := ioutil.TempDir("", "bpdaily-lenient")
+	require.Nil(t, err, "could not create temporary directory: %v", err)
+	defer os.RemoveAll(dir)
+	rejectsPath := filepath.Join(dir, "rejects.csv")
+
+	os.Args = []string{"TestLenientFlag", "daily", "--lenient", "--rejects", rejectsPath,
+		"testdata/badbody.in.csv", filepath.Join(dir, "daily.csv")}
+	main()
+	require.Nil(t, executeError, "lenient conversion should have succeeded: %v", executeError)
+	content, err := ioutil.ReadFile(rejectsPath)
+	require.Nil(t, err, "could not read rejects report: %v", err)
+	require.Contains(t, string(content), "Source,Line,Text,Reason,Outcome\n")
+	require.Contains(t, string(content), ",wrong number of fields,rejected\n")
+	require.Contains(t, string(content), "testdata/badbody.in.csv,18,")
+
+	// Without the flag, the same file is still refused
+	beforeEach()
+	os.Args = []string{"TestLenientFlag", "validate", "testdata/badbody.in.csv"}
+	main()
+	require.Equal(t, exitFormat, exitCode, "strict parsing should refuse the bad body")
+
+	// A rejects report of "-" goes to standard error, leaving the daily output on standard output alone
+	beforeEach()
+	report := &bytes.Buffer{}
+	stderr = report
+	redirected := redirectStdout(t)
+	defer redirected.restore()
+	os.Args = []string{"TestLenientFlag", "daily", "--lenient", "--rejects", "-", "testdata/badbody.in.csv"}
+	main()
+	require.Nil(t, executeError, "lenient conversion should have succeeded: %v", executeError)
+	require.Contains(t, report.String(), "Source,Line,Text,Reason,Outcome\n")
+	require.NotContains(t, redirected.contents(), "Source,Line,Text,Reason,Outcome")
+	require.Contains(t, redirected.contents(), "Date Time 1,")
+}
+
+// TestImplausibleFlag checks that the --implausible and --limits flags are applied.
+func TestImplausibleFlag(t *testing.T) {
+
+	// Make sure the main() function does not exit altogether
+	beforeEach()
+	output := captureStdout()
+
+	// The happy path readings all have pulses below 70, which are only flagged by default
+	os.Args = []string{"TestImplausibleFlag", "validate", "--limits", "pulse=70-200", "testdata/happypath.in.csv"}
+	main()
+	require.Nil(t, executeError, "validate should have succeeded: %v", executeError)
+	require.Contains(t, output.String(), "22 readings over 14 days, 2 records ignored")
+
+	// Unless they are to be rejected
+	beforeEach()
+	output = captureStdout()
+	os.Args = []string{"TestImplausibleFlag", "validate", "--limits", "pulse=70-200", "--implausible", "reject", "testdata/happypath.in.csv"}
+	main()
+	require.Nil(t, executeError, "validate should have succeeded: %v", executeError)
+	require.Contains(t, output.String(), "0 readings over 0 days, 24 records ignored")
+
+	// Fixing them clears the pulses instead
+	beforeEach()
+	output = captureStdout()
+	os.Args = []string{"TestImplausibleFlag", "validate", "--limits", "pulse=70-200", "--implausible", "fix", "testdata/happypath.in.csv"}
+	main()
+	require.Nil(t, executeError, "validate should have succeeded: %v", executeError)
+	require.Contains(t, output.String(), "22 readings over 14 days, 2 records ignored")
+
+	// An unknown action is a usage error
+	beforeEach()
+	os.Args = []string{"TestImplausibleFlag", "validate", "--implausible", "ignore", "testdata/happypath.in.csv"}
+	main()
+	require.Equal(t, exitUsage, exitCode, "an unknown action should be a usage error")
+}
+
+// TestInputFlag checks that the --input flag merges further inputs into the first.
+func TestInputFlag(t *testing.T) {
+
+	// Make sure the main() function does not exit altogether
+	beforeEach()
+	output := captureStdout()
+
+	os.Args = []string{"TestInputFlag", "validate", "testdata/merge/export-2020-05-01.csv",
+		"--input", "testdata/merge/export-2020-05-0[3].csv"}
+	main()
+	require.Nil(t, executeError, "validate should have succeeded: %v", executeError)
+	require.Equal(t, "testdata/merge/export-2020-05-01.csv plus 1 more is valid: 7 readings over 5 days, "+
+		"1 records ignored, 3 duplicates left out\n", output.String())
+
+	// Inputs that do not exist are input errors
+	beforeEach()
+	os.Args = []string{"TestInputFlag", "stats", "testdata/happypath.in.csv", "--input", "testdata/nothing-*.csv"}
+	main()
+	require.Equal(t, exitInput, exitCode, "an unmatched pattern should be an input error")
+}
+
+// TestUpdateCommand checks that the update command merges new readings into a daily file.
+func TestUpdateCommand(t *testing.T) {
+
+	// Make sure the main() function does not exit altogether
+	beforeEach()
+	dir, err := ioutil.TempDir("", "bpdaily-update")
+	require.Nil(t, err, "could not create temporary directory: %v", err)
+	defer os.RemoveAll(dir)
+	dailyPath := filepath.Join(dir, "daily.csv")
+
+	os.Args = []string{"TestUpdateCommand", "daily", "testdata/merge/export-2020-05-01.csv", dailyPath}
+	main()
+	require.Nil(t, executeError, "daily should have succeeded: %v", executeError)
+
+	beforeEach()
+	os.Args = []string{"TestUpdateCommand", "update", dailyPath, "testdata/merge/export-2020-05-03.csv"}
+	main()
+	require.Nil(t, executeError, "update should have succeeded: %v", executeError)
+	content, err := ioutil.ReadFile(dailyPath)
+	require.Nil(t, err, "could not read daily file: %v", err)
+	require.Contains(t, string(content), "\n2020-05-03 07:12:11,91,63,49,\n")
+
+	// Slots that the daily file does not have conflict with its content, which is fine
+	beforeEach()
+	os.Args = []string{"TestUpdateCommand", "update", "--slots", "Morning=04:00-12:00", dailyPath, "testdata/merge/export-2020-05-03.csv"}
+	main()
+	require.Equal(t, exitUsage, exitCode, "mismatched slots should be a usage error")
+
+	// A daily file must be named
+	beforeEach()
+	os.Args = []string{"TestUpdateCommand", "update"}
+	main()
+	require.Equal(t, exitInput, exitCode, "updating standard input should be an input error")
+}
+
+// TestFillGapsFlag checks that the --fill-gaps flags write every day, and that the stats
+// command reports the days without readings.
+func TestFillGapsFlag(t *testing.T) {
+
+	// Make sure the main() function does not exit altogether
+	beforeEach()
+	redirected := redirectStdout(t)
+	defer redirected.restore()
+
+	os.Args = []string{"TestFillGapsFlag", "daily", "--fill-gaps", "--fill-to", "2020-05-30", "testdata/happypath.in.csv"}
+	main()
+	require.Nil(t, executeError, "daily should have succeeded: %v", executeError)
+	require.Contains(t, redirected.contents(), "Date,Date Time 1,")
+	require.Contains(t, redirected.contents(), "\n2020-05-09\n")
+	require.True(t, strings.HasSuffix(redirected.contents(), "\n2020-05-30\n"))
+
+	// A bad date is a usage error
+	beforeEach()
+	os.Args = []string{"TestFillGapsFlag", "daily", "--fill-gaps", "--fill-to", "May 30", "testdata/happypath.in.csv"}
+	main()
+	require.Equal(t, exitUsage, exitCode, "a bad date should be a usage error")
+
+	// The stats command lists the gaps
+	beforeEach()
+	output := captureStdout()
+	os.Args = []string{"TestFillGapsFlag", "stats", "testdata/happypath.in.csv"}
+	main()
+	require.Nil(t, executeError, "stats should have succeeded: %v", executeError)
+	require.Contains(t, output.String(), "Gaps:      19 days without readings in 1 streak\n           2020-05-09 to 2020-05-27, 19 days\n")
+}
+
+// TestDateRangeFlags checks that the --from, --to and --last options leave out readings.
+func TestDateRangeFlags(t *testing.T) {
+
+	// Make sure the main() function does not exit altogether
+	beforeEach()
+	output := captureStdout()
+	os.Args = []string{"TestDateRangeFlags", "validate", "--from", "2020-04-29", "--to", "2020-05-01", "testdata/happypath.in.csv"}
+	main()
+	require.Nil(t, executeError, "validate should have succeeded: %v", executeError)
+	require.Contains(t, output.String(), "7 readings over 3 days")
+
+	// A bad period is a usage error
+	beforeEach()
+	os.Args = []string{"TestDateRangeFlags", "validate", "--last", "30 days", "testdata/happypath.in.csv"}
+	main()
+	require.Equal(t, exitUsage, exitCode, "a bad period should be a usage error")
+
+	// As is giving both --from and --last, in either order
+	for _, args := range [][]string{{"--from", "2020-05-01", "--last", "30d"}, {"--last", "30d", "--from", "2020-05-01"}} {
+		beforeEach()
+		os.Args = append([]string{"TestDateRangeFlags", "validate"}, append(args, "testdata/happypath.in.csv")...)
+		main()
+		require.Equal(t, exitUsage, exitCode, "--from and --last together should be a usage error")
+	}
+}
+
+// TestZoneFlags checks that the --input-zone and --display-zone options move readings
+// between days.
+func TestZoneFlags(t *testing.T) {
+
+	// Make sure the main() function does not exit altogether
+	beforeEach()
+	redirected := redirectStdout(t)
+	defer redirected.restore()
+
+	os.Args = []string{"TestZoneFlags", "daily", "--input-zone", "America/New_York", "--display-zone", "Asia/Tokyo", "testdata/happypath.in.csv"}
+	main()
+	require.Nil(t, executeError, "daily should have succeeded: %v", executeError)
+	require.Contains(t, redirected.contents(), "\n2020-04-26 19:16:43,97,68,58,\n")
+
+	// An unknown zone is a usage error
+	beforeEach()
+	os.Args = []string{"TestZoneFlags", "validate", "--display-zone", "Nowhere/Land", "testdata/happypath.in.csv"}
+	main()
+	require.Equal(t, exitUsage, exitCode, "an unknown zone should be a usage error")
+}
+
+// TestDayStartFlag checks that the --day-start option moves early readings to the day before.
+func TestDayStartFlag(t *testing.T) {
+
+	// Make sure the main() function does not exit altogether
+	beforeEach()
+	output := captureStdout()
+	os.Args = []string{"TestDayStartFlag", "validate", "--day-start", "08:00", "testdata/happypath.in.csv"}
+	main()
+	require.Nil(t, executeError, "validate should have succeeded: %v", executeError)
+	require.Contains(t, output.String(), "22 readings over 15 days")
+
+	// An afternoon day start is a usage error
+	beforeEach()
+	os.Args = []string{"TestDayStartFlag", "validate", "--day-start", "13:00", "testdata/happypath.in.csv"}
+	main()
+	require.Equal(t, exitUsage, exitCode, "an afternoon day start should be a usage error")
+}
+
+// TestGroupByFlag checks that the --group-by option aggregates the daily output.
+func TestGroupByFlag(t *testing.T) {
+
+	// Make sure the main() function does not exit altogether
+	beforeEach()
+	redirected := redirectStdout(t)
+	defer redirected.restore()
+
+	os.Args = []string{"TestGroupByFlag", "daily", "--group-by", "month", "testdata/happypath.in.csv"}
+	main()
+	require.Nil(t, executeError, "daily should have succeeded: %v", executeError)
+	require.Contains(t, redirected.contents(), "Period,Start,End,Days With Readings,")
+	require.Contains(t, redirected.contents(), "\n2020-05,2020-05-01,2020-05-31,9,")
+
+	// An unknown period is a usage error
+	beforeEach()
+	os.Args = []string{"TestGroupByFlag", "daily", "--group-by", "year", "testdata/happypath.in.csv"}
+	main()
+	require.Equal(t, exitUsage, exitCode, "an unknown period should be a usage error")
+}
+
+// TestRollingFlag checks that the --rolling option adds rolling averages to the daily
+// output and the stats command.
+func TestRollingFlag(t *testing.T) {
+
+	// Make sure the main() function does not exit altogether
+	beforeEach()
+	output := captureStdout()
+	os.Args = []string{"TestRollingFlag", "stats", "--rolling", "7,30", "testdata/happypath.in.csv"}
+	main()
+	require.Nil(t, executeError, "stats should have succeeded: %v", executeError)
+	require.Contains(t, output.String(), "Trend:     systolic -0.15, diastolic -0.06, pulse -0.08 per day\n")
+	require.Contains(t, output.String(), "Rolling:   7 days to 2020-05-28, mean 92.0/67.0, pulse 54.5 from 2 readings\n"+
+		"           30 days to 2020-05-28, mean 96.5/67.7, pulse 54.7 from 18 readings\n")
+
+	// The daily output has the rolling average columns
+	beforeEach()
+	redirected := redirectStdout(t)
+	defer redirected.restore()
+	os.Args = []string{"TestRollingFlag", "daily", "--rolling", "7", "testdata/happypath.in.csv"}
+	main()
+	require.Nil(t, executeError, "daily should have succeeded: %v", executeError)
+	require.Contains(t, redirected.contents(), ",7 Day Mean Systolic,")
+
+	// A bad window is a usage error
+	beforeEach()
+	os.Args = []string{"TestRollingFlag", "daily", "--rolling", "week", "testdata/happypath.in.csv"}
+	main()
+	require.Equal(t, exitUsage, exitCode, "a bad window should be a usage error")
+}
+
+// TestProtocolCommand confirms that the protocol command reports on a home monitoring protocol.
+func TestProtocolCommand(t *testing.T) {
+
+	// Make sure the main() function does not exit altogether
+	beforeEach()
+	output := captureStdout()
+	os.Args = []string{"TestProtocolCommand", "protocol", "testdata/protocol.in.csv"}
+	main()
+	require.Nil(t, executeError, "protocol should have succeeded: %v", executeError)
+	require.Contains(t, output.String(), "Protocol:  ESH home monitoring, 7 days from 2020-06-01 to 2020-06-07\n")
+	require.Contains(t, output.String(), "           2020-06-01  Morning 2  Evening 2  (first day, not counted)\n")
+	require.Contains(t, output.String(), "Counted:   23 of 24 readings\n"+
+		"Mean:      134.8/85.8 mmHg, pulse 61.9\n"+
+		"Result:    at or above the home threshold of 135/85 mmHg\n"+
+		"Missed:    1 session short of readings\n"+
+		"           2020-06-04 Evening, 1 of 2 readings\n"+
+		"Left out:  4 from the first day, 1 extra in a session, 1 outside the sessions\n")
+
+	// Too few days leave too few readings, which is reported rather than an error
+	beforeEach()
+	output = captureStdout()
+	os.Args = []string{"TestProtocolCommand", "protocol", "--days", "3", "testdata/protocol.in.csv"}
+	main()
+	require.Nil(t, executeError, "protocol should have succeeded: %v", executeError)
+	require.Contains(t, output.String(), "Result:    not valid, at least 12 readings must be counted\n")
+
+	// A bad number of days is a usage error
+	beforeEach()
+	os.Args = []string{"TestProtocolCommand", "protocol", "--days", "0", "testdata/protocol.in.csv"}
+	main()
+	require.Equal(t, exitUsage, exitCode, "a bad number of days should be a usage error")
 }